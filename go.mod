@@ -2,13 +2,6 @@ module micromonsta2-patch-tools
 
 go 1.23.1
 
-require (
-	github.com/Pallinder/go-randomdata v1.2.0
-	github.com/xeipuuv/gojsonschema v1.2.0
-)
+require github.com/Pallinder/go-randomdata v1.2.0
 
-require (
-	github.com/xeipuuv/gojsonpointer v0.0.0-20180127040702-4e3ac2762d5f // indirect
-	github.com/xeipuuv/gojsonreference v0.0.0-20180127040603-bd5ef7bd5415 // indirect
-	golang.org/x/text v0.26.0 // indirect
-)
+require golang.org/x/text v0.26.0 // indirect
@@ -1,94 +1,50 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
-	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Pallinder/go-randomdata"
-	"github.com/xeipuuv/gojsonschema"
+
+	"micromonsta2-patch-tools/patch"
 )
 
 //go:embed P292_Init.syx
 var initPatch []byte
 
-//go:embed specs/*.json
+//go:embed specs specs-*
 var specsFS embed.FS
 
+// defaultProfile names the profile stored in specs/, other profiles are
+// stored in specs-<name>/
+const defaultProfile = "default"
+
 //go:embed micromonsta_patch_schema.json
 var schemaData []byte
 
-// ParamInfo holds metadata for a single synth parameter
-type ParamInfo struct {
-	Min         int    `json:"min"`
-	Max         int    `json:"max"`
-	Default     int    `json:"default"`
-	SysexOffset int    `json:"sysex_offset"`
-	SysexLength int    `json:"sysex_length"`
-	Scale       string `json:"scale"`
-	Unit        string `json:"unit"`
-	Section     string `json:"section"`
-}
-
-// propSchema holds JSON schema constraints for a patch property
-type propSchema struct {
-	Minimum int `json:"minimum"`
-	Maximum int `json:"maximum"`
-}
-
 // PresetInfo holds information about a preset for sorting
 type PresetInfo struct {
-	Data     []byte
+	Patch    *patch.Patch
 	Name     string
 	Category string
 	CatCode  byte
 	Index    int // Original position for stable sorting
 }
 
-const patchSize = 176
-
-// categoryCodes maps category names to SysEx category byte values
-var categoryCodes = map[string]byte{
-	"Bass":       0x00,
-	"Lead":       0x01,
-	"Pad":        0x02,
-	"Keys":       0x03,
-	"Organ":      0x04,
-	"String":     0x05,
-	"Brass":      0x06,
-	"Percussion": 0x07,
-	"Drone":      0x08,
-	"Noise":      0x09,
-	"SFX":        0x0A,
-	"Arp":        0x0B,
-	"Misc":       0x0C,
-	"User1":      0x0D,
-	"User2":      0x0E,
-	"User3":      0x0F,
-}
-
-// getCategoryName returns the category name for a given byte code
-func getCategoryName(catByte byte) string {
-	for name, code := range categoryCodes {
-		if code == catByte {
-			return name
-		}
-	}
-	return "Unknown"
-}
-
 // getCategoryOrder returns a sort order for categories
 func getCategoryOrder(category string) int {
 	order := map[string]int{
@@ -116,9 +72,49 @@ func getCategoryOrder(category string) int {
 	return 16
 }
 
-func main() {
-	rand.Seed(time.Now().UnixNano())
+// initBase returns the embedded INIT patch used as template for new presets
+func initBase() *patch.Patch {
+	p, err := patch.New(initPatch)
+	if err != nil {
+		log.Fatalf("embedded init patch is invalid: %v", err)
+	}
+	return p
+}
+
+// loadBase returns the template for generated presets: the --base file if
+// given, else <specDir>/<Category>.syx or <specDir>/base.syx if present, else
+// the embedded INIT patch
+func loadBase(path, specDir, category string) *patch.Patch {
+	if path != "" {
+		base, err := patch.Load(path)
+		if err != nil {
+			log.Fatalf("invalid base patch: %v", err)
+		}
+		fmt.Printf("Using base patch '%s' from %s\n", base.Name(), path)
+		return base
+	}
+
+	// Spec directory bases are read like its specs, from the binary for
+	// built-in profiles
+	fsys, err := specDirFS(specDir)
+	if err != nil {
+		log.Fatalf("failed to read spec directory '%s': %v", specDir, err)
+	}
+	for _, candidate := range []string{category + ".syx", "base.syx"} {
+		if _, err := fs.Stat(fsys, candidate); err != nil {
+			continue
+		}
+		base, err := patch.LoadFS(fsys, candidate)
+		if err != nil {
+			log.Fatalf("invalid base patch in '%s': %v", specDir, err)
+		}
+		fmt.Printf("Using base patch '%s' from %s\n", base.Name(), filepath.Join(specDir, candidate))
+		return base
+	}
+	return initBase()
+}
 
+func main() {
 	// Custom flag parsing to handle missing arguments better
 	args := os.Args[1:]
 
@@ -148,23 +144,187 @@ func main() {
 
 	// flags
 	specDir := flag.String("specs", "specs", "Directory containing category JSON spec files")
+	profile := flag.String("profile", "", "Name of a built-in spec profile to use instead of --specs (see --list-profiles)")
+	listProfiles := flag.Bool("list-profiles", false, "List built-in spec profiles and the categories they cover")
 	category := flag.String("category", "", "Category of presets to generate or replace (e.g. Lead)")
 	count := flag.Int("count", 0, "Number of new presets to generate")
 	editFile := flag.String("edit", "", "Existing SysEx file to edit")
 	replace := flag.String("replace", "", "Comma-separated preset positions or names to replace")
 	replaceWith := flag.String("replace-with", "", "Comma-separated list of single preset .syx files to use as replacements")
+	insertFiles := flag.String("insert", "", "With --edit, comma-separated list of SysEx files or directories whose presets are inserted into the bundle")
+	insertAt := flag.Int("at", 0, "With --insert, 1-based position of the first inserted preset (default: end of the bundle, first free slot of a bank); with --group --bank, first slot")
+	bank := flag.Int("bank", 0, "Number of slots of a bank: --group pads its output with INIT patches, --describe and --insert/--delete/--move work on slots (default: size recorded in the descriptor)")
+	deleteList := flag.String("delete", "", "With --edit, comma-separated preset positions or names to delete")
+	moveList := flag.String("move", "", "With --edit, comma-separated moves of the form <position or name>:<new position> (e.g. \"warm:1\")")
 	describeFile := flag.String("describe", "", "SysEx file to describe contents")
 	splitFile := flag.String("split", "", "SysEx file to split into individual preset files")
 	extractFrom := flag.String("extract", "", "Comma-separated list of preset positions (1-based) or names to extract from bundle")
 	groupFiles := flag.String("group", "", "Comma-separated list of SysEx files or directories to group into a single bundle")
 	sortFile := flag.String("sort", "", "SysEx file to sort presets by category then alphabetically")
+	sortBy := flag.String("sort-by", "category,name", "With --sort, comma-separated sort keys: category, name, position or a parameter name; prefix with - to reverse, give a category order as category:Lead/Pad/Bass")
+	orderFrom := flag.String("order-from", "", "With --sort, descriptor .txt file listing the preset names in the wanted order")
 	renameTo := flag.String("rename", "", "New name for the preset when editing single preset files (max 8 characters)")
 	changeCategoryTo := flag.String("change-category", "", "New category for the preset when editing single preset files (e.g. Lead, Bass, Pad)")
+	showParams := flag.Bool("params", false, "With --describe, decode every spec parameter of each preset grouped by section")
+	lintSpecs := flag.String("lint-specs", "", "Spec directory to check for unknown names, bad ranges and offsets, and inconsistencies between files")
+	validateFiles := flag.String("validate", "", "Comma-separated list of SysEx files or directories to validate")
+	exportJSON := flag.String("export-json", "", "SysEx file (single preset or bundle) to export as JSON")
+	importJSON := flag.String("import-json", "", "JSON file to convert back to SysEx")
+	diffFile := flag.String("diff", "", "SysEx file to compare with the file given as next argument (--diff a.syx b.syx)")
+	alignBy := flag.String("align", "position", "How --diff pairs presets of two bundles: position or name")
+	mutateFile := flag.String("mutate", "", "Single preset file to generate --count variations of")
+	amount := flag.Float64("amount", 0.1, "With --mutate, maximum change of each parameter as a fraction of its range (0-1)")
+	morphFile := flag.String("morph", "", "Single preset file to morph into the preset given as next argument (--morph a.syx b.syx)")
+	steps := flag.Int("steps", 8, "With --morph, number of intermediate presets to create")
+	crossover := flag.Float64("crossover", 0.5, "With --morph, point (0-1) where enum parameters switch from the first to the second preset")
+	breedFile := flag.String("breed", "", "Single preset file to breed with the preset given as next argument (--breed p1.syx p2.syx)")
+	mutation := flag.Float64("mutation", 0, "With --breed, amount (0-1) by which offspring are mutated after crossover (default: no mutation)")
+	basePath := flag.String("base", "", "Single preset file used as template for generated presets instead of the spec directory's base patch or the embedded INIT patch")
+	lock := flag.String("lock", "", "Comma-separated sections or parameters to keep from the base patch when generating (e.g. \"Filter,ENV1_Attack\")")
+	only := flag.String("only", "", "Comma-separated sections or parameters to randomize when generating, keeping all others from the base patch")
+	workers := flag.Int("workers", runtime.NumCPU(), "Number of parallel workers drawing candidates during generation (results do not depend on it)")
+	seed := flag.Int64("seed", 0, "Seed for reproducible generation (default: random, printed and recorded in bundle descriptors)")
+	dedupeFiles := flag.String("dedupe", "", "Comma-separated list of SysEx files or directories to search for near-duplicate presets")
+	threshold := flag.Float64("threshold", 0.01, "With --dedupe, maximum parameter distance (0-1) between presets considered duplicates")
+	similarFile := flag.String("similar", "", "Single preset file to find the closest presets to in the --in directory tree")
+	searchDir := flag.String("in", "presets", "With --similar, directory searched recursively for .syx files")
+	top := flag.Int("top", 10, "With --similar, number of closest presets to list")
+	learnSpec := flag.String("learn-spec", "", "Comma-separated list of SysEx files or directories to learn a --category spec from")
+	undo := flag.Bool("undo", false, "Undo the last operation recorded in the journal of the current directory")
+	history := flag.Bool("history", false, "List the operations recorded in the journal of the current directory")
+	flag.BoolVar(&dryRun, "dry-run", false, "Show which files would be created, modified or deleted without changing anything")
+	outPath := flag.String("out", "", "Output path for --export-json, --import-json (default: input path with new extension), --learn-spec (default: <Category>.json) and --dedupe (bundle without duplicates, default: none)")
 	flag.Parse()
 
+	// Allow flags after positional arguments (e.g. --diff a.syx b.syx --align name)
+	var positional []string
+	for flag.NArg() > 0 {
+		positional = append(positional, flag.Arg(0))
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	// An explicit --seed makes generation reproducible; otherwise pick one
+	// and report it so a good batch can still be recreated
+	seedSet, specsSet := false, false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			seedSet = true
+		case "specs":
+			specsSet = true
+		}
+	})
+	if !seedSet {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+
+	// A built-in profile replaces the spec directory for every mode
+	if *profile != "" {
+		if specsSet {
+			fmt.Println("Error: --profile and --specs cannot be used together")
+			os.Exit(1)
+		}
+		var ok bool
+		if *specDir, ok = builtinProfile(*profile); !ok {
+			fmt.Printf("Error: unknown profile '%s'. Available profiles: %s\n", *profile, strings.Join(profileNames(), ", "))
+			os.Exit(1)
+		}
+	}
+
+	if dryRun {
+		defer func() {
+			if dryRunSkipped {
+				fmt.Println("Dry run: no files were changed")
+			}
+		}()
+	}
+	// Commands run anywhere inside a library share its journal
+	root, err := patch.LibraryRoot(".")
+	if err != nil {
+		log.Fatalf("failed to locate library: %v", err)
+	}
+	journal = patch.OpenJournal(root, strings.Join(os.Args[1:], " "))
+
+	// journal modes
+	if *history {
+		runHistory()
+		return
+	}
+	if *undo {
+		runUndo()
+		return
+	}
+
+	// validate mode
+	if *validateFiles != "" {
+		if !runValidate(*validateFiles, *specDir) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// built-in spec profiles
+	if *listProfiles {
+		runListProfiles()
+		return
+	}
+
+	// spec lint mode
+	if *lintSpecs != "" {
+		if !runLintSpecs(*lintSpecs) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// diff mode
+	if *diffFile != "" {
+		if len(positional) != 1 {
+			fmt.Println("Error: --diff requires two files: --diff a.syx b.syx")
+			os.Exit(1)
+		}
+		if *alignBy != "position" && *alignBy != "name" {
+			fmt.Printf("Error: unknown --align value '%s' (expected position or name)\n", *alignBy)
+			os.Exit(1)
+		}
+		runDiff(*diffFile, positional[0], *specDir, *alignBy == "name")
+		return
+	}
+
+	// near-duplicate detection mode
+	if *dedupeFiles != "" {
+		if *threshold < 0 || *threshold > 1 {
+			fmt.Println("Error: --threshold must be between 0 and 1")
+			os.Exit(1)
+		}
+		runDedupe(*dedupeFiles, *specDir, *threshold, *outPath)
+		return
+	}
+
+	// similarity search mode
+	if *similarFile != "" {
+		if *top <= 0 {
+			fmt.Println("Error: --top must be at least 1")
+			os.Exit(1)
+		}
+		runSimilar(*similarFile, *searchDir, *specDir, *top)
+		return
+	}
+
+	// JSON conversion modes
+	if *exportJSON != "" {
+		runExportJSON(*exportJSON, *specDir, *outPath)
+		return
+	}
+	if *importJSON != "" {
+		runImportJSON(*importJSON, *specDir, *outPath)
+		return
+	}
+
 	// describe mode
 	if *describeFile != "" {
-		runDescribe(*describeFile)
+		runDescribe(*describeFile, *specDir, *showParams, *bank)
 		return
 	}
 
@@ -182,13 +342,102 @@ func main() {
 
 	// group mode
 	if *groupFiles != "" {
-		runGroup(*groupFiles)
+		runGroup(*groupFiles, *bank, *insertAt, rng)
 		return
 	}
 
 	// sort mode
 	if *sortFile != "" {
-		runSort(*sortFile)
+		runSort(*sortFile, *specDir, *sortBy, *orderFrom)
+		return
+	}
+
+	// mutate mode
+	if *mutateFile != "" {
+		if *count <= 0 {
+			*count = 1
+		}
+		if *amount <= 0 || *amount > 1 {
+			fmt.Println("Error: --amount must be greater than 0 and at most 1")
+			os.Exit(1)
+		}
+		fmt.Printf("Using seed %d\n", *seed)
+		runMutate(*mutateFile, *specDir, *count, *amount, rng, *seed)
+		return
+	}
+
+	// morph mode
+	if *morphFile != "" {
+		if len(positional) != 1 {
+			fmt.Println("Error: --morph requires two files: --morph a.syx b.syx")
+			os.Exit(1)
+		}
+		if *steps <= 0 {
+			fmt.Println("Error: --steps must be at least 1")
+			os.Exit(1)
+		}
+		if *crossover < 0 || *crossover > 1 {
+			fmt.Println("Error: --crossover must be between 0 and 1")
+			os.Exit(1)
+		}
+		runMorph(*morphFile, positional[0], *specDir, *steps, *crossover, rng)
+		return
+	}
+
+	// breed mode
+	if *breedFile != "" {
+		if len(positional) != 1 {
+			fmt.Println("Error: --breed requires two files: --breed p1.syx p2.syx")
+			os.Exit(1)
+		}
+		if *count <= 0 {
+			*count = 1
+		}
+		if *mutation < 0 || *mutation > 1 {
+			fmt.Println("Error: --mutation must be between 0 and 1")
+			os.Exit(1)
+		}
+		fmt.Printf("Using seed %d\n", *seed)
+		runBreed(*breedFile, positional[0], *specDir, *count, *mutation, rng, *seed)
+		return
+	}
+
+	// learn spec mode
+	if *learnSpec != "" {
+		catCode, ok := patch.CategoryCode(*category)
+		if !ok {
+			fmt.Println("Error: --learn-spec requires a valid --category")
+			printAvailableCategories()
+			os.Exit(1)
+		}
+		runLearnSpec(*learnSpec, *specDir, *category, catCode, *outPath)
+		return
+	}
+
+	// bundle restructuring modes
+	if *insertFiles != "" || *deleteList != "" || *moveList != "" {
+		if *editFile == "" {
+			fmt.Println("Error: --insert, --delete and --move require --edit")
+			os.Exit(1)
+		}
+		modes := 0
+		for _, v := range []string{*insertFiles, *deleteList, *moveList} {
+			if v != "" {
+				modes++
+			}
+		}
+		if modes > 1 {
+			fmt.Println("Error: use only one of --insert, --delete and --move at a time")
+			os.Exit(1)
+		}
+		switch {
+		case *insertFiles != "":
+			runInsert(*editFile, *insertFiles, *insertAt, *bank)
+		case *deleteList != "":
+			runDelete(*editFile, *deleteList, *bank)
+		default:
+			runMove(*editFile, *moveList, *bank)
+		}
 		return
 	}
 
@@ -201,7 +450,7 @@ func main() {
 	var catCode byte
 	if *category != "" {
 		var ok bool
-		catCode, ok = categoryCodes[*category]
+		catCode, ok = patch.CategoryCode(*category)
 		if !ok {
 			fmt.Printf("Error: unknown category '%s'.\n", *category)
 			printAvailableCategories()
@@ -213,7 +462,7 @@ func main() {
 	var changeCatCode byte
 	if *changeCategoryTo != "" {
 		var ok bool
-		changeCatCode, ok = categoryCodes[*changeCategoryTo]
+		changeCatCode, ok = patch.CategoryCode(*changeCategoryTo)
 		if !ok {
 			fmt.Printf("Error: unknown category '%s' for --change-category.\n", *changeCategoryTo)
 			printAvailableCategories()
@@ -221,64 +470,35 @@ func main() {
 		}
 	}
 
-	var params map[string]ParamInfo
+	var params patch.Spec
 	var allowed map[string][]int
-	var schema *gojsonschema.Schema
+	var checker *patch.Checker
+	var base *patch.Patch
 
-	// Only load specs and schema if we need them (for random generation)
+	// Only load specs, schema and base patch if we need them (for random generation)
 	if *category != "" {
-		// load spec JSON
-		jsonPath := fmt.Sprintf("%s/%s.json", *specDir, *category)
-		raw, err := loadSpec(jsonPath, *specDir)
-		if err != nil {
-			log.Fatalf("failed to read spec JSON '%s': %v", jsonPath, err)
-		}
-		if err := json.Unmarshal(raw, &params); err != nil {
-			log.Fatalf("failed to parse spec JSON: %v", err)
-		}
-
-		// compile JSON schema
-		schemaLoader := gojsonschema.NewBytesLoader(schemaData)
-		schema, err = gojsonschema.NewSchema(schemaLoader)
-		if err != nil {
-			log.Fatalf("failed to compile JSON schema: %v", err)
-		}
-		var schemaStruct struct {
-			Properties map[string]propSchema `json:"properties"`
-		}
-		if err := json.Unmarshal(schemaData, &schemaStruct); err != nil {
-			log.Fatalf("failed to parse JSON schema: %v", err)
+		params, allowed, checker = loadGenerator(*specDir, *category, false)
+		base = loadBase(*basePath, *specDir, *category)
+	} else if *basePath != "" {
+		fmt.Println("Error: --base requires --category")
+		os.Exit(1)
+	}
+	if *lock != "" || *only != "" {
+		if *lock != "" && *only != "" {
+			fmt.Println("Error: --lock and --only cannot be used together")
+			os.Exit(1)
 		}
-		schemaProps := schemaStruct.Properties
-
-		// validate spec fields
-		for name := range params {
-			if _, exists := schemaProps[name]; !exists {
-				log.Fatalf("spec JSON contains unknown parameter '%s' not in schema", name)
-			}
+		if *category == "" {
+			fmt.Println("Error: --lock and --only require --category")
+			os.Exit(1)
 		}
+		allowed = lockParams(params, allowed, *lock, *only)
+		checkLockedValues(base, params, allowed)
+	}
 
-		// build allowed ranges
-		allowed = make(map[string][]int, len(params))
-		for pname, info := range params {
-			minVal, maxVal := info.Min, info.Max
-			sch := schemaProps[pname]
-			if sch.Minimum > minVal {
-				minVal = sch.Minimum
-			}
-			if sch.Maximum < maxVal {
-				maxVal = sch.Maximum
-			}
-			if maxVal < minVal {
-				maxVal = minVal
-			}
-			rng := maxVal - minVal + 1
-			vals := make([]int, rng)
-			for i := 0; i < rng; i++ {
-				vals[i] = minVal + i
-			}
-			allowed[pname] = vals
-		}
+	if *workers < 1 {
+		fmt.Println("Error: --workers must be at least 1")
+		os.Exit(1)
 	}
 
 	// choose mode
@@ -294,7 +514,8 @@ func main() {
 			runChangeCategory(*editFile, changeCatCode)
 		} else if *category != "" {
 			// Random generation replacement mode
-			runEdit(*editFile, *replace, catCode, params, allowed, schema)
+			fmt.Printf("Using seed %d\n", *seed)
+			runEdit(*editFile, *replace, catCode, base, params, allowed, checker, *workers, rng, *seed)
 		} else if *replaceWith != "" {
 			// File-based replacement mode
 			runEditWithFiles(*editFile, *replace, *replaceWith)
@@ -307,43 +528,98 @@ func main() {
 			fmt.Println("Error: --count requires --category")
 			os.Exit(1)
 		}
-		runGenerate(*count, *category, catCode, params, allowed, schema)
+		fmt.Printf("Using seed %d\n", *seed)
+		runGenerate(*count, *category, catCode, base, params, allowed, checker, *workers, rng, *seed)
 	} else {
 		flag.Usage()
 		os.Exit(1)
 	}
 }
 
-func runSort(path string) {
-	data, err := ioutil.ReadFile(path)
+// loadGenerator loads the spec and rules of a category and the JSON schema,
+// builds the allowed value ranges used by random generation and compiles the
+// schema ranges and rules into a checker for candidates. A spec range that
+// does not overlap the schema makes generation impossible; when deriving
+// presets from existing ones (fromPreset), whose values are clamped to the
+// schema, such parameters are left out of the allowed ranges instead and keep
+// the value of the preset.
+func loadGenerator(specDir, category string, fromPreset bool) (patch.Spec, map[string][]int, *patch.Checker) {
+	// load spec JSON
+	params, rules, err := specLoader.Load(specDir, category)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	schemaProps, err := patch.ParseSchema(schemaData)
+	if err != nil {
+		log.Fatalf("failed to parse JSON schema: %v", err)
+	}
+
+	// validate spec fields
+	for name := range params {
+		if _, exists := schemaProps[name]; !exists {
+			log.Fatalf("spec JSON contains unknown parameter '%s' not in schema", name)
+		}
+	}
+
+	// build allowed ranges
+	allowed, disjoint := patch.AllowedValues(params, schemaProps)
+	if len(disjoint) > 0 {
+		if !fromPreset {
+			log.Fatalf("spec ranges do not overlap the schema, no valid preset can be generated: %s", strings.Join(disjoint, ", "))
+		}
+		fmt.Printf("Warning: spec ranges do not overlap the schema, keeping the preset values of %s\n", strings.Join(disjoint, ", "))
+	}
+	for _, r := range rules {
+		for _, pname := range r.Params() {
+			if _, exists := params[pname]; !exists {
+				log.Fatalf("rule '%s' uses unknown parameter '%s'", r.Name, pname)
+			}
+		}
+	}
+	checker, err := patch.NewChecker(params, schemaProps, rules)
+	if err != nil {
+		log.Fatalf("failed to compile constraints: %v", err)
+	}
+	return params, allowed, checker
+}
+
+func runSort(path, specDir, sortBy, orderFrom string) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		log.Fatalf("failed to read sysex file: %v", err)
 	}
+	bundle, err := patch.ParseBundle(data)
+	if err != nil {
+		log.Fatalf("failed to parse sysex file: %v", err)
+	}
 
-	n := len(data) / patchSize
+	n := bundle.Len()
 	if n <= 1 {
 		fmt.Printf("File %s contains only %d preset, nothing to sort.\n", path, n)
 		return
 	}
 
-	fmt.Printf("Sorting %d presets in %s by category then alphabetically...\n", n, path)
+	var keys []sortKey
+	if orderFrom != "" {
+		fmt.Printf("Sorting %d presets in %s in the order of %s...\n", n, path, orderFrom)
+	} else {
+		keys, err = parseSortKeys(sortBy, specDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Sorting %d presets in %s by %s...\n", n, path, sortBy)
+	}
 
 	// Extract preset information
 	presets := make([]PresetInfo, n)
-	for i := 0; i < n; i++ {
-		off := i * patchSize
-		presetData := make([]byte, patchSize)
-		copy(presetData, data[off:off+patchSize])
-
-		name := strings.TrimRight(string(presetData[8:16]), " \x00")
-		catByte := presetData[16]
-		category := getCategoryName(catByte)
-
+	for i, p := range bundle.Patches {
 		presets[i] = PresetInfo{
-			Data:     presetData,
-			Name:     name,
-			Category: category,
-			CatCode:  catByte,
+			Patch:    p,
+			Name:     p.Name(),
+			Category: p.Category(),
+			CatCode:  p.CategoryCode(),
 			Index:    i, // Original position for stable sorting
 		}
 	}
@@ -354,25 +630,17 @@ func runSort(path string) {
 		fmt.Printf("  %2d: %s (%s)\n", i+1, preset.Name, preset.Category)
 	}
 
-	// Sort presets: first by category order, then alphabetically by name, then by original index for stability
-	sort.Slice(presets, func(i, j int) bool {
-		catOrderI := getCategoryOrder(presets[i].Category)
-		catOrderJ := getCategoryOrder(presets[j].Category)
-
-		if catOrderI != catOrderJ {
-			return catOrderI < catOrderJ
-		}
-
-		// Same category, sort alphabetically by name (case-insensitive)
-		nameI := strings.ToLower(presets[i].Name)
-		nameJ := strings.ToLower(presets[j].Name)
-		if nameI != nameJ {
-			return nameI < nameJ
-		}
-
-		// Same name, maintain stable sort using original index
-		return presets[i].Index < presets[j].Index
-	})
+	// In a bank only the used slots are sorted, the free slots stay at the end
+	var free []PresetInfo
+	if bankSlots(path, 0) > 0 {
+		presets, free = splitFreeSlots(presets, initBase())
+	}
+	if orderFrom != "" {
+		presets = orderFromDescriptor(presets, orderFrom)
+	} else {
+		sortPresets(presets, keys)
+	}
+	presets = append(presets, free...)
 
 	// Show new order
 	fmt.Println("\nNew order:")
@@ -380,32 +648,21 @@ func runSort(path string) {
 		fmt.Printf("  %2d: %s (%s)\n", i+1, preset.Name, preset.Category)
 	}
 
-	// Rebuild the sysex data
-	newData := make([]byte, len(data))
-	for i, preset := range presets {
-		off := i * patchSize
-		copy(newData[off:off+patchSize], preset.Data)
-	}
-
-	// Create backup
-	backupPath := strings.TrimSuffix(path, ".syx") + "_backup_" + strconv.FormatInt(time.Now().Unix(), 10) + ".syx"
-	err = ioutil.WriteFile(backupPath, data, 0644)
-	if err != nil {
-		log.Printf("Warning: failed to create backup file: %v", err)
-	} else {
-		fmt.Printf("Created backup: %s\n", backupPath)
+	// Rebuild the bundle
+	sorted := &patch.Bundle{}
+	for _, preset := range presets {
+		sorted.Append(preset.Patch)
 	}
 
 	// Write sorted file
-	err = ioutil.WriteFile(path, newData, 0644)
-	if err != nil {
+	if err := saveBundle(sorted, path); err != nil {
 		log.Fatalf("failed to write sorted sysex file: %v", err)
 	}
 
-	fmt.Printf("Sorted presets written to %s\n", path)
+	reportDone("Sorted presets written to %s\n", path)
 
 	// Update descriptor file if it exists or if this is a multi-preset bundle
-	if err := writeDescriptorFile(path, newData); err != nil {
+	if err := writeDescriptorFile(path, sorted); err != nil {
 		log.Printf("Warning: %v", err)
 	}
 
@@ -419,95 +676,558 @@ func runSort(path string) {
 	fmt.Printf("Sorting complete: %d presets moved to new positions\n", changes)
 }
 
-func runRename(filePath, newName string) {
-	// Validate new name length
-	if len(newName) > 8 {
-		fmt.Printf("Warning: new name '%s' is longer than 8 characters, truncating to '%s'\n", newName, newName[:8])
-		newName = newName[:8]
+// sortKey compares two presets for one key of --sort-by
+type sortKey func(a, b PresetInfo) int
+
+// parseSortKeys builds the comparisons of a --sort-by list. Keys are
+// category (optionally with an explicit order, category:Lead/Pad/Bass), name,
+// position or a spec parameter, decoded with the spec of each preset's
+// category; a leading '-' reverses a key.
+func parseSortKeys(sortBy, specDir string) ([]sortKey, error) {
+	specFor := decodeSpecs(specDir, "sorting")
+
+	var keys []sortKey
+	for _, tok := range strings.Split(sortBy, ",") {
+		tok = strings.TrimSpace(tok)
+		desc := strings.HasPrefix(tok, "-")
+		tok = strings.TrimPrefix(tok, "-")
+		field, arg, _ := strings.Cut(tok, ":")
+
+		var compare sortKey
+		switch strings.ToLower(field) {
+		case "":
+			return nil, fmt.Errorf("empty sort key in '%s'", sortBy)
+		case "category":
+			order := make(map[string]int)
+			if arg != "" {
+				for i, name := range strings.Split(arg, "/") {
+					code, ok := patch.CategoryCode(strings.TrimSpace(name))
+					if !ok {
+						return nil, fmt.Errorf("unknown category '%s' in sort key '%s'", name, tok)
+					}
+					order[patch.CategoryName(code)] = i
+				}
+			}
+			// Categories not listed follow the listed ones in the default order
+			rank := func(category string) int {
+				if o, ok := order[category]; ok {
+					return o
+				}
+				return len(order) + getCategoryOrder(category)
+			}
+			compare = func(a, b PresetInfo) int { return rank(a.Category) - rank(b.Category) }
+		case "name":
+			compare = func(a, b PresetInfo) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) }
+		case "position":
+			compare = func(a, b PresetInfo) int { return a.Index - b.Index }
+		default:
+			categories, err := specLoader.Categories(specDir)
+			if err != nil || len(categories) == 0 {
+				return nil, fmt.Errorf("no spec found to decode parameter '%s'", field)
+			}
+			name := ""
+			for _, n := range specFor(categories[0]).Names() {
+				if strings.EqualFold(n, field) {
+					name = n
+					break
+				}
+			}
+			if name == "" {
+				return nil, fmt.Errorf("unknown sort key '%s' (expected category, name, position or a parameter name)", field)
+			}
+			// Presets whose spec lacks the parameter sort first
+			value := func(p PresetInfo) int {
+				v, err := p.Patch.Param(specFor(p.Category), name)
+				if err != nil {
+					return -1
+				}
+				return v
+			}
+			compare = func(a, b PresetInfo) int { return value(a) - value(b) }
+		}
+		if desc {
+			asc := compare
+			compare = func(a, b PresetInfo) int { return asc(b, a) }
+		}
+		keys = append(keys, compare)
 	}
+	return keys, nil
+}
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); err != nil {
-		log.Fatalf("failed to access file '%s': %v", filePath, err)
+// splitFreeSlots separates the presets holding the empty patch from the used
+// ones, keeping the order of both
+func splitFreeSlots(presets []PresetInfo, empty *patch.Patch) (used, free []PresetInfo) {
+	for _, p := range presets {
+		if patch.IsEmptySlot(p.Patch, empty) {
+			free = append(free, p)
+		} else {
+			used = append(used, p)
+		}
 	}
+	return used, free
+}
 
-	// Read the file
-	data, err := ioutil.ReadFile(filePath)
+// sortPresets sorts presets comparing keys in turn; the original index keeps
+// the sort stable
+func sortPresets(presets []PresetInfo, keys []sortKey) {
+	sort.Slice(presets, func(i, j int) bool {
+		for _, key := range keys {
+			if c := key(presets[i], presets[j]); c != 0 {
+				return c < 0
+			}
+		}
+		return presets[i].Index < presets[j].Index
+	})
+}
+
+// orderFromDescriptor orders presets as listed in a descriptor file.
+// Duplicate names are matched in bundle order; presets the file does not
+// list keep their relative order at the end.
+func orderFromDescriptor(presets []PresetInfo, descPath string) []PresetInfo {
+	raw, err := os.ReadFile(descPath)
 	if err != nil {
-		log.Fatalf("failed to read sysex file: %v", err)
+		log.Fatalf("failed to read order file: %v", err)
 	}
 
-	// Check if it's a single preset
-	if len(data) != patchSize {
-		log.Fatalf("file '%s' is not a single preset file (size: %d bytes, expected: %d)", filePath, len(data), patchSize)
+	used := make([]bool, len(presets))
+	var ordered []PresetInfo
+	for _, e := range patch.ParseDescriptor(string(raw)) {
+		found := false
+		for i, p := range presets {
+			if used[i] || !strings.EqualFold(p.Name, e.Name) {
+				continue
+			}
+			if e.Category != "" && !strings.EqualFold(p.Category, e.Category) {
+				continue
+			}
+			used[i] = true
+			ordered = append(ordered, p)
+			found = true
+			break
+		}
+		if !found {
+			fmt.Printf("Warning: '%s' listed in %s not found in the bundle\n", e.Name, descPath)
+		}
 	}
-
-	// Extract current preset info
-	currentName := strings.TrimRight(string(data[8:16]), " \x00")
-	catByte := data[16]
-	category := getCategoryName(catByte)
-
-	fmt.Printf("Renaming preset '%s' (%s) to '%s'\n", currentName, category, newName)
-
-	// Update the preset name in the sysex data
-	for i := 0; i < 8; i++ {
-		if i < len(newName) {
-			data[8+i] = newName[i]
-		} else {
-			data[8+i] = 0x20 // space padding
+	for i, p := range presets {
+		if !used[i] {
+			fmt.Printf("Warning: '%s' (%s) not listed in %s, moved to the end\n", p.Name, p.Category, descPath)
+			ordered = append(ordered, p)
 		}
 	}
+	return ordered
+}
 
-	// Generate new filename
-	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
-	dir := filepath.Dir(filePath)
-	newFileName := fmt.Sprintf("%s_%s_%s.syx", category, newName, timeStr)
-	newFilePath := filepath.Join(dir, newFileName)
+// dryRun makes file operations report what they would do instead of doing it
+var dryRun bool
 
-	// Write the updated preset to the new file
-	err = ioutil.WriteFile(newFilePath, data, 0644)
-	if err != nil {
-		log.Fatalf("failed to write renamed preset: %v", err)
-	}
+// dryRunSkipped is set once --dry-run has skipped a change
+var dryRunSkipped bool
 
-	// Remove the original file
-	err = os.Remove(filePath)
-	if err != nil {
-		log.Printf("Warning: failed to remove original file '%s': %v", filePath, err)
-	}
+// journal records the prior state of every file changed by the command
+var journal *patch.Journal
 
-	fmt.Printf("Successfully renamed preset:\n")
-	fmt.Printf("  Old: %s -> '%s' (%s)\n", filepath.Base(filePath), currentName, category)
-	fmt.Printf("  New: %s -> '%s' (%s)\n", filepath.Base(newFilePath), newName, category)
+// writeFile writes data to path unless it already holds it, or reports the
+// write in --dry-run mode
+func writeFile(path string, data []byte) error {
+	_, err := updateFile(path, data)
+	return err
 }
 
-func runChangeCategory(filePath string, newCatCode byte) {
-	// Check if file exists
-	if _, err := os.Stat(filePath); err != nil {
-		log.Fatalf("failed to access file '%s': %v", filePath, err)
+// updateFile is writeFile, also returning whether path was written
+func updateFile(path string, data []byte) (bool, error) {
+	// Rewriting identical content is not a change, e.g. the descriptor
+	// refreshed by --describe
+	existing, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	}
+	if dryRun {
+		action := "create"
+		if err == nil {
+			action = "modify"
+		}
+		reportDryRun("Would %s %s (%d bytes)\n", action, path, len(data))
+		return false, nil
+	}
+	err = journal.Record(path, patch.ActionModify, func() error {
+		return os.WriteFile(path, data, 0644)
+	})
+	return err == nil, err
+}
+
+// saveBundle writes a bundle to path, or reports the write in --dry-run mode
+func saveBundle(bundle *patch.Bundle, path string) error {
+	if err := writeFile(path, bundle.Bytes()); err != nil {
+		return fmt.Errorf("failed to write sysex file: %v", err)
+	}
+	return nil
+}
+
+// removeFile deletes path, or reports the deletion in --dry-run mode
+func removeFile(path string) error {
+	if dryRun {
+		reportDryRun("Would delete %s\n", path)
+		return nil
+	}
+	return journal.Record(path, patch.ActionDelete, func() error {
+		return os.Remove(path)
+	})
+}
+
+// makeDir creates a directory and its parents, or reports it in --dry-run mode
+func makeDir(dir string) error {
+	if dryRun {
+		if _, err := os.Stat(dir); err != nil {
+			reportDryRun("Would create directory %s\n", dir)
+		}
+		return nil
+	}
+
+	// Create and record missing directories outermost first, undo removes
+	// them in reverse
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		missing = append(missing, d)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		d := missing[i]
+		if err := journal.Record(d, patch.ActionMkdir, func() error { return os.Mkdir(d, 0755) }); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// reportDryRun prints a change skipped by --dry-run
+func reportDryRun(format string, args ...interface{}) {
+	dryRunSkipped = true
+	fmt.Printf("[dry-run] "+format, args...)
+}
+
+// reportDone prints a completion message, which is left out in --dry-run mode
+// since the operations were only reported
+func reportDone(format string, args ...interface{}) {
+	if !dryRun {
+		fmt.Printf(format, args...)
 	}
+}
 
-	// Read the file
-	data, err := ioutil.ReadFile(filePath)
+// loadEditBundle reads a bundle to restructure, returning its number of bank
+// slots (0 for a plain bundle). Banks are padded with empty slots up to their
+// size.
+func loadEditBundle(path string, slots int) (*patch.Bundle, int) {
+	bundle, err := patch.LoadBundle(path)
 	if err != nil {
-		log.Fatalf("failed to read sysex file: %v", err)
+		log.Fatalf("%v", err)
+	}
+	slots = bankSlots(path, slots)
+	if slots > 0 {
+		if err := bundle.Pad(slots, initBase()); err != nil {
+			fmt.Printf("Error: %s: %v\n", path, err)
+			os.Exit(1)
+		}
+	}
+	return bundle, slots
+}
+
+// saveEditedBundle writes the restructured bundle and its descriptor and
+// shows the new order
+func saveEditedBundle(path string, bundle *patch.Bundle, slots int) {
+	fmt.Println("New order:")
+	fmt.Print(indent(bundle.Descriptor(), "  "))
+
+	if err := saveBundle(bundle, path); err != nil {
+		log.Fatalf("%v", err)
+	}
+	reportDone("Updated presets written to %s\n", path)
+	if slots > 0 {
+		fmt.Printf("Bank of %d slots, %d free\n", slots, len(bundle.FreeSlots(initBase())))
+	}
+	if err := writeDescriptorFile(path, bundle, bankNotes(path, slots)...); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// indent prefixes every line of text
+func indent(text, prefix string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// bankSlots returns the number of slots of the bundle at path: the --bank
+// value if given, else the size declared in its descriptor, else 0
+func bankSlots(path string, slots int) int {
+	if slots > 0 {
+		return slots
+	}
+	for _, note := range readDescriptorNotes(descriptorPath(path)) {
+		if n, ok := patch.ParseBankNote(note); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+// bankNotes returns the descriptor notes of the bundle at path with the bank
+// size declared, or removed for a plain bundle
+func bankNotes(path string, slots int) []string {
+	var notes []string
+	for _, note := range readDescriptorNotes(descriptorPath(path)) {
+		if _, ok := patch.ParseBankNote(note); !ok {
+			notes = append(notes, note)
+		}
+	}
+	if slots > 0 {
+		notes = append(notes, patch.BankNote(slots))
+	}
+	return notes
+}
+
+// runInsert inserts the presets of the given files into a bundle, starting at
+// the 1-based position at (0 appends them). In a bank, presets go into the
+// free slots from slot at (default 1) on, and other presets keep their slot.
+func runInsert(editFile, fileList string, at, slots int) {
+	bundle, slots := loadEditBundle(editFile, slots)
+	if at == 0 {
+		at = bundle.Len() + 1
+		if slots > 0 {
+			at = 1
+		}
+	}
+	if at < 1 || at > bundle.Len()+1 || (slots > 0 && at > slots) {
+		fmt.Printf("Error: --at %d out of range, %s has %d presets\n", at, editFile, bundle.Len())
+		os.Exit(1)
+	}
+
+	files := collectSyxFiles(fileList)
+	var inserted []*patch.Patch
+	for _, path := range files {
+		b, err := patch.LoadBundle(path)
+		if err != nil {
+			fmt.Printf("Warning: skipping %v\n", err)
+			continue
+		}
+		inserted = append(inserted, b.Patches...)
+	}
+	if len(inserted) == 0 {
+		fmt.Println("Error: no presets found to insert")
+		os.Exit(1)
+	}
+
+	empty := initBase()
+	var free []int
+	if slots > 0 {
+		for _, i := range bundle.FreeSlots(empty) {
+			if i >= at-1 {
+				free = append(free, i)
+			}
+		}
+		if len(free) < len(inserted) {
+			fmt.Printf("Error: %d presets do not fit in the %d free slots from slot %d of the %d-slot bank %s\n",
+				len(inserted), len(free), at, slots, editFile)
+			os.Exit(1)
+		}
+	}
+
+	existing := make(map[string]struct{})
+	for _, p := range bundle.Patches {
+		if !patch.IsEmptySlot(p, empty) {
+			existing[strings.ToLower(p.Name())] = struct{}{}
+		}
+	}
+	for i, p := range inserted {
+		if _, ok := existing[strings.ToLower(p.Name())]; ok {
+			fmt.Printf("Warning: a preset named '%s' already exists in %s\n", p.Name(), editFile)
+		}
+		if slots > 0 {
+			if err := bundle.Set(free[i], p); err != nil {
+				log.Fatalf("%v", err)
+			}
+			fmt.Printf("Inserting '%s' (%s) into slot %d\n", p.Name(), p.Category(), free[i]+1)
+			continue
+		}
+		if err := bundle.Insert(at-1+i, p); err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("Inserting '%s' (%s) at position %d\n", p.Name(), p.Category(), at+i)
+	}
+	saveEditedBundle(editFile, bundle, slots)
+	fmt.Printf("Inserted %d presets into %s\n", len(inserted), editFile)
+}
+
+// runDelete removes the presets designated by positions or names from a
+// bundle. In a bank, their slots are emptied instead so that other presets
+// keep their slot.
+func runDelete(editFile, deleteList string, slots int) {
+	bundle, slots := loadEditBundle(editFile, slots)
+	targets := parseReplaceList(deleteList, bundle.Names())
+	if len(targets) == 0 {
+		fmt.Println("Error: no valid presets to delete specified")
+		os.Exit(1)
+	}
+	warnUnmatchedTokens(deleteList, targets)
+
+	indices := make(map[int]struct{})
+	for _, t := range targets {
+		indices[t.index] = struct{}{}
+	}
+	if len(indices) == bundle.Len() && slots == 0 {
+		fmt.Printf("Error: cannot delete every preset of %s\n", editFile)
+		os.Exit(1)
+	}
+
+	// Remove from the end so earlier indices stay valid
+	empty := initBase()
+	deleted := 0
+	for i := bundle.Len() - 1; i >= 0; i-- {
+		if _, ok := indices[i]; !ok {
+			continue
+		}
+		if slots > 0 {
+			p := bundle.Patches[i]
+			if patch.IsEmptySlot(p, empty) {
+				fmt.Printf("Warning: slot %d is already empty\n", i+1)
+				continue
+			}
+			if err := bundle.Set(i, empty.Clone()); err != nil {
+				log.Fatalf("%v", err)
+			}
+			fmt.Printf("Clearing slot %d: '%s' (%s)\n", i+1, p.Name(), p.Category())
+			deleted++
+			continue
+		}
+		p, err := bundle.Remove(i)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("Deleting %d: '%s' (%s)\n", i+1, p.Name(), p.Category())
+		deleted++
+	}
+	saveEditedBundle(editFile, bundle, slots)
+	fmt.Printf("Deleted %d presets from %s\n", deleted, editFile)
+}
+
+// runMove applies moves of the form <position or name>:<new position> in
+// order, each one on the result of the previous. In a bank, the preset swaps
+// slots with the preset in the target slot.
+func runMove(editFile, moveList string, slots int) {
+	bundle, slots := loadEditBundle(editFile, slots)
+	empty := initBase()
+	moved := 0
+	for _, tok := range strings.Split(moveList, ",") {
+		tok = strings.TrimSpace(tok)
+		sep := strings.LastIndex(tok, ":")
+		if sep < 0 {
+			fmt.Printf("Error: invalid move '%s', expected <position or name>:<new position>\n", tok)
+			os.Exit(1)
+		}
+		to, err := strconv.Atoi(strings.TrimSpace(tok[sep+1:]))
+		if err != nil || to < 1 || to > bundle.Len() {
+			fmt.Printf("Error: invalid new position in '%s', %s has %d presets\n", tok, editFile, bundle.Len())
+			os.Exit(1)
+		}
+		targets := parseReplaceList(tok[:sep], bundle.Names())
+		if len(targets) != 1 {
+			fmt.Printf("Error: '%s' does not designate a single preset\n", tok[:sep])
+			os.Exit(1)
+		}
+
+		from := targets[0].index
+		if slots > 0 {
+			p, q := bundle.Patches[from], bundle.Patches[to-1]
+			bundle.Patches[from], bundle.Patches[to-1] = q, p
+			fmt.Printf("Moving '%s' (%s) from slot %d to %d", p.Name(), p.Category(), from+1, to)
+			if from != to-1 && !patch.IsEmptySlot(q, empty) {
+				fmt.Printf(", swapping with '%s' (%s)", q.Name(), q.Category())
+			}
+			fmt.Println()
+			moved++
+			continue
+		}
+		p, err := bundle.Remove(from)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := bundle.Insert(to-1, p); err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("Moving '%s' (%s) from position %d to %d\n", p.Name(), p.Category(), from+1, to)
+		moved++
+	}
+	saveEditedBundle(editFile, bundle, slots)
+	fmt.Printf("Applied %d moves in %s\n", moved, editFile)
+}
+
+func runRename(filePath, newName string) {
+	// Validate new name length
+	if len(newName) > 8 {
+		fmt.Printf("Warning: new name '%s' is longer than 8 characters, truncating to '%s'\n", newName, newName[:8])
+		newName = newName[:8]
+	}
+
+	// Read the single preset file
+	p, err := patch.Load(filePath)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Extract current preset info
+	currentName := p.Name()
+	category := p.Category()
+
+	fmt.Printf("Renaming preset '%s' (%s) to '%s'\n", currentName, category, newName)
+
+	// Update the preset name in the sysex data
+	p.SetName(newName)
+
+	// Generate new filename
+	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
+	dir := filepath.Dir(filePath)
+	newFileName := fmt.Sprintf("%s_%s_%s.syx", category, newName, timeStr)
+	newFilePath := filepath.Join(dir, newFileName)
+
+	// Write the updated preset to the new file
+	err = writeFile(newFilePath, p.Bytes())
+	if err != nil {
+		log.Fatalf("failed to write renamed preset: %v", err)
+	}
+
+	// Remove the original file
+	err = removeFile(filePath)
+	if err != nil {
+		log.Printf("Warning: failed to remove original file '%s': %v", filePath, err)
 	}
 
-	// Check if it's a single preset
-	if len(data) != patchSize {
-		log.Fatalf("file '%s' is not a single preset file (size: %d bytes, expected: %d)", filePath, len(data), patchSize)
+	reportDone("Successfully renamed preset:\n")
+	fmt.Printf("  Old: %s -> '%s' (%s)\n", filepath.Base(filePath), currentName, category)
+	fmt.Printf("  New: %s -> '%s' (%s)\n", filepath.Base(newFilePath), newName, category)
+}
+
+func runChangeCategory(filePath string, newCatCode byte) {
+	// Read the single preset file
+	p, err := patch.Load(filePath)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
 	// Extract current preset info
-	currentName := strings.TrimRight(string(data[8:16]), " \x00")
-	currentCatByte := data[16]
-	currentCategory := getCategoryName(currentCatByte)
-	newCategory := getCategoryName(newCatCode)
+	currentName := p.Name()
+	currentCategory := p.Category()
+	newCategory := patch.CategoryName(newCatCode)
 
 	fmt.Printf("Changing category of preset '%s' from %s to %s\n", currentName, currentCategory, newCategory)
 
 	// Update the category in the sysex data
-	data[16] = newCatCode
+	p.SetCategory(newCatCode)
 
 	// Generate new filename
 	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
@@ -516,18 +1236,18 @@ func runChangeCategory(filePath string, newCatCode byte) {
 	newFilePath := filepath.Join(dir, newFileName)
 
 	// Write the updated preset to the new file
-	err = ioutil.WriteFile(newFilePath, data, 0644)
+	err = writeFile(newFilePath, p.Bytes())
 	if err != nil {
 		log.Fatalf("failed to write updated preset: %v", err)
 	}
 
 	// Remove the original file
-	err = os.Remove(filePath)
+	err = removeFile(filePath)
 	if err != nil {
 		log.Printf("Warning: failed to remove original file '%s': %v", filePath, err)
 	}
 
-	fmt.Printf("Successfully changed category:\n")
+	reportDone("Successfully changed category:\n")
 	fmt.Printf("  Old: %s -> '%s' (%s)\n", filepath.Base(filePath), currentName, currentCategory)
 	fmt.Printf("  New: %s -> '%s' (%s)\n", filepath.Base(newFilePath), currentName, newCategory)
 }
@@ -539,41 +1259,22 @@ func runRenameAndChangeCategory(filePath, newName string, newCatCode byte) {
 		newName = newName[:8]
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); err != nil {
-		log.Fatalf("failed to access file '%s': %v", filePath, err)
-	}
-
-	// Read the file
-	data, err := ioutil.ReadFile(filePath)
+	// Read the single preset file
+	p, err := patch.Load(filePath)
 	if err != nil {
-		log.Fatalf("failed to read sysex file: %v", err)
-	}
-
-	// Check if it's a single preset
-	if len(data) != patchSize {
-		log.Fatalf("file '%s' is not a single preset file (size: %d bytes, expected: %d)", filePath, len(data), patchSize)
+		log.Fatalf("%v", err)
 	}
 
 	// Extract current preset info
-	currentName := strings.TrimRight(string(data[8:16]), " \x00")
-	currentCatByte := data[16]
-	currentCategory := getCategoryName(currentCatByte)
-	newCategory := getCategoryName(newCatCode)
+	currentName := p.Name()
+	currentCategory := p.Category()
+	newCategory := patch.CategoryName(newCatCode)
 
 	fmt.Printf("Renaming preset '%s' (%s) to '%s' (%s)\n", currentName, currentCategory, newName, newCategory)
 
-	// Update the preset name in the sysex data
-	for i := 0; i < 8; i++ {
-		if i < len(newName) {
-			data[8+i] = newName[i]
-		} else {
-			data[8+i] = 0x20 // space padding
-		}
-	}
-
-	// Update the category in the sysex data
-	data[16] = newCatCode
+	// Update the preset name and category in the sysex data
+	p.SetName(newName)
+	p.SetCategory(newCatCode)
 
 	// Generate new filename
 	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
@@ -582,52 +1283,143 @@ func runRenameAndChangeCategory(filePath, newName string, newCatCode byte) {
 	newFilePath := filepath.Join(dir, newFileName)
 
 	// Write the updated preset to the new file
-	err = ioutil.WriteFile(newFilePath, data, 0644)
+	err = writeFile(newFilePath, p.Bytes())
 	if err != nil {
 		log.Fatalf("failed to write updated preset: %v", err)
 	}
 
 	// Remove the original file
-	err = os.Remove(filePath)
+	err = removeFile(filePath)
 	if err != nil {
 		log.Printf("Warning: failed to remove original file '%s': %v", filePath, err)
 	}
 
-	fmt.Printf("Successfully renamed and changed category:\n")
+	reportDone("Successfully renamed and changed category:\n")
 	fmt.Printf("  Old: %s -> '%s' (%s)\n", filepath.Base(filePath), currentName, currentCategory)
 	fmt.Printf("  New: %s -> '%s' (%s)\n", filepath.Base(newFilePath), newName, newCategory)
 }
 
 // suggestCategoryFromFile reads a preset file and suggests the current category
 func suggestCategoryFromFile(filePath string) {
-	// Check if file exists and is readable
-	if _, err := os.Stat(filePath); err != nil {
-		return // Can't help if file doesn't exist
+	// Can't help if the file is missing, unreadable or not a single preset
+	p, err := patch.Load(filePath)
+	if err != nil {
+		return
+	}
+
+	currentCategory := p.Category()
+	fmt.Printf("Current preset: '%s' (%s)\n", p.Name(), currentCategory)
+	fmt.Printf("Hint: use --change-category \"NewCategory\" to change from %s to another category.\n", currentCategory)
+	printAvailableCategories()
+}
+
+// runGroup combines files into a new bundle. With slots > 0 it writes a bank
+// of that many slots, placing the presets from slot at (default 1) and
+// filling the other slots with INIT patches.
+func runGroup(fileList string, slots, at int, rng *rand.Rand) {
+	validFiles := collectSyxFiles(fileList)
+
+	if len(validFiles) == 0 {
+		fmt.Println("Error: no valid sysex files found to group")
+		return
+	}
+
+	fmt.Printf("Grouping %d sysex files into a single bundle:\n", len(validFiles))
+
+	// Read and combine all presets
+	combined := &patch.Bundle{}
+
+	for _, path := range validFiles {
+		bundle, err := patch.LoadBundle(path)
+		if err != nil {
+			fmt.Printf("Warning: failed to read '%s': %v\n", path, err)
+			continue
+		}
+
+		fmt.Printf("  %s: %d preset(s)\n", filepath.Base(path), bundle.Len())
+		combined.Append(bundle.Patches...)
+	}
+
+	totalPresets := combined.Len()
+	if totalPresets == 0 {
+		fmt.Println("Error: no presets found in any files")
+		return
+	}
+
+	// Check for name conflicts and report them
+	nameConflicts := findNameConflicts(combined.Patches)
+	if len(nameConflicts) > 0 {
+		fmt.Printf("Warning: found %d duplicate preset names:\n", len(nameConflicts))
+		for name, count := range nameConflicts {
+			fmt.Printf("  '%s' appears %d times\n", name, count)
+		}
+		fmt.Println("Proceeding anyway - duplicates will be preserved")
+	}
+
+	// Lay presets out in bank slots
+	output := combined
+	var notes []string
+	if slots > 0 {
+		if at == 0 {
+			at = 1
+		}
+		if at < 1 || at-1+totalPresets > slots {
+			fmt.Printf("Error: %d presets from slot %d do not fit in a bank of %d slots\n", totalPresets, at, slots)
+			os.Exit(1)
+		}
+		output = &patch.Bundle{}
+		if err := output.Pad(at-1, initBase()); err != nil {
+			log.Fatalf("%v", err)
+		}
+		output.Append(combined.Patches...)
+		if err := output.Pad(slots, initBase()); err != nil {
+			log.Fatalf("%v", err)
+		}
+		notes = append(notes, patch.BankNote(slots))
+		fmt.Printf("Placing presets in slots %d-%d of a bank of %d slots\n", at, at-1+totalPresets, slots)
 	}
 
-	// Try to read the file
-	data, err := ioutil.ReadFile(filePath)
+	// Create output directory and files
+	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
+	bundleRaw := uniqueName(rng, make(map[string]struct{}))
+	bundleName := strings.Title(strings.ToLower(bundleRaw))
+	subDir := filepath.Join("presets", bundleName)
+	err := makeDir(subDir)
 	if err != nil {
-		return // Can't help if can't read file
+		log.Fatalf("failed to create output directory: %v", err)
 	}
 
-	// Check if it's a single preset
-	if len(data) != patchSize {
-		return // Can't help if not a valid preset
+	// Write combined bundle file
+	combinedName := fmt.Sprintf("%s_grouped_%s.syx", bundleName, timeStr)
+	combinedPath := filepath.Join(subDir, combinedName)
+	if err := saveBundle(output, combinedPath); err != nil {
+		log.Fatalf("failed to write combined file: %v", err)
 	}
 
-	// Extract current preset info
-	currentName := strings.TrimRight(string(data[8:16]), " \x00")
-	catByte := data[16]
-	currentCategory := getCategoryName(catByte)
+	reportDone("Wrote combined bundle with %d presets to %s\n", totalPresets, combinedPath)
 
-	fmt.Printf("Current preset: '%s' (%s)\n", currentName, currentCategory)
-	fmt.Printf("Hint: use --change-category \"NewCategory\" to change from %s to another category.\n", currentCategory)
-	printAvailableCategories()
+	// Write individual preset files
+	for _, p := range combined.Patches {
+		filename := fmt.Sprintf("%s_%s_%s.syx", p.Category(), p.Name(), timeStr)
+		filepath := filepath.Join(subDir, filename)
+
+		err = writeFile(filepath, p.Bytes())
+		if err != nil {
+			log.Printf("Warning: failed to write individual preset %s: %v", filename, err)
+		}
+	}
+
+	reportDone("Wrote %d individual preset files to %s\n", totalPresets, subDir)
+
+	// Write descriptor file
+	if err := writeDescriptorFile(combinedPath, output, notes...); err != nil {
+		log.Printf("Warning: %v", err)
+	}
 }
 
-func runGroup(fileList string) {
-	// Parse file list
+// collectSyxFiles expands a comma-separated list of files and directories
+// into the list of .syx files it designates
+func collectSyxFiles(fileList string) []string {
 	filePaths := strings.Split(fileList, ",")
 	var validFiles []string
 
@@ -646,7 +1438,7 @@ func runGroup(fileList string) {
 
 		if info.IsDir() {
 			// Include all .syx files in directory
-			entries, err := ioutil.ReadDir(path)
+			entries, err := os.ReadDir(path)
 			if err != nil {
 				fmt.Printf("Warning: failed to read directory '%s': %v\n", path, err)
 				continue
@@ -666,308 +1458,1207 @@ func runGroup(fileList string) {
 		// Single file
 		validFiles = append(validFiles, path)
 	}
+	return validFiles
+}
 
-	if len(validFiles) == 0 {
-		fmt.Println("Error: no valid sysex files found to group")
-		return
-	}
-
-	fmt.Printf("Grouping %d sysex files into a single bundle:\n", len(validFiles))
-
-	// Read and combine all presets
-	var allPresets [][]byte
-	var totalPresets int
+func findNameConflicts(presets []*patch.Patch) map[string]int {
+	nameCounts := make(map[string]int)
+	conflicts := make(map[string]int)
 
-	for _, path := range validFiles {
-		data, err := ioutil.ReadFile(path)
-		if err != nil {
-			fmt.Printf("Warning: failed to read '%s': %v\n", path, err)
-			continue
+	for _, p := range presets {
+		name := p.Name()
+		nameCounts[name]++
+		if nameCounts[name] > 1 {
+			conflicts[name] = nameCounts[name]
 		}
+	}
 
-		numPresets := len(data) / patchSize
-		fmt.Printf("  %s: %d preset(s)\n", filepath.Base(path), numPresets)
+	return conflicts
+}
 
-		// Extract individual presets from this file
-		for i := 0; i < numPresets; i++ {
-			off := i * patchSize
-			preset := make([]byte, patchSize)
-			copy(preset, data[off:off+patchSize])
-			allPresets = append(allPresets, preset)
-		}
-		totalPresets += numPresets
+func runSplit(path string) {
+	bundle, err := patch.LoadBundle(path)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	if totalPresets == 0 {
+	n := bundle.Len()
+	if n <= 1 {
+		fmt.Printf("File %s contains only %d preset, nothing to split.\n", path, n)
+		return
+	}
+
+	fmt.Printf("Splitting %d presets from %s into individual files:\n", n, path)
+
+	// Create output directory based on input filename
+	baseName := strings.TrimSuffix(filepath.Base(path), ".syx")
+	outputDir := filepath.Join("presets", baseName+"_split")
+	err = makeDir(outputDir)
+	if err != nil {
+		log.Fatalf("failed to create output directory: %v", err)
+	}
+
+	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
+
+	for i, p := range bundle.Patches {
+		name := p.Name()
+		catName := p.Category()
+
+		// Create filename: Category_PresetName_timestamp.syx
+		filename := fmt.Sprintf("%s_%s_%s.syx", catName, name, timeStr)
+		filepath := filepath.Join(outputDir, filename)
+
+		// Write individual preset file
+		err = writeFile(filepath, p.Bytes())
+		if err != nil {
+			log.Printf("Warning: failed to write %s: %v", filepath, err)
+			continue
+		}
+
+		fmt.Printf("  %2d: %s (%s) -> %s\n", i+1, name, catName, filename)
+	}
+
+	reportDone("Split complete. %d individual preset files written to %s\n", n, outputDir)
+}
+
+func runExtract(path, extractList string) {
+	bundle, err := patch.LoadBundle(path)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	n := bundle.Len()
+	if n <= 1 {
+		fmt.Printf("File %s contains only %d preset(s), use single preset editing instead.\n", path, n)
+		return
+	}
+
+	fmt.Printf("Extracting specific presets from %s (%d total presets):\n", path, n)
+
+	// Extract existing names for position/name lookup
+	existingNames := bundle.Names()
+
+	// Parse extraction targets
+	targets := parseReplaceList(extractList, existingNames)
+
+	if len(targets) == 0 {
+		fmt.Println("Error: no valid extraction targets specified")
+		return
+	}
+
+	// Warn for unmatched tokens
+	warnUnmatchedTokens(extractList, targets)
+
+	// Create output directory based on input filename
+	baseName := strings.TrimSuffix(filepath.Base(path), ".syx")
+	outputDir := filepath.Join("presets", baseName+"_extracted")
+	err = makeDir(outputDir)
+	if err != nil {
+		log.Fatalf("failed to create output directory: %v", err)
+	}
+
+	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
+	extractedCount := 0
+
+	// Extract each target preset
+	for _, target := range targets {
+		idx := target.index
+		if idx < 0 || idx >= n {
+			fmt.Printf("Warning: position %d out of range, skipping\n", idx+1)
+			continue
+		}
+
+		p := bundle.Patches[idx]
+		name := p.Name()
+		catName := p.Category()
+
+		// Create filename: Category_PresetName_timestamp.syx
+		filename := fmt.Sprintf("%s_%s_%s.syx", catName, name, timeStr)
+		filePath := filepath.Join(outputDir, filename)
+
+		// Write individual preset file
+		err = writeFile(filePath, p.Bytes())
+		if err != nil {
+			log.Printf("Warning: failed to write %s: %v", filePath, err)
+			continue
+		}
+
+		fmt.Printf("  Extracted %2d: %s (%s) -> %s\n", idx+1, name, catName, filename)
+		extractedCount++
+	}
+
+	if extractedCount > 0 {
+		reportDone("Extraction complete. %d preset files written to %s\n", extractedCount, outputDir)
+	} else {
+		fmt.Println("No presets were extracted.")
+		// Remove empty directory
+		if !dryRun {
+			os.Remove(outputDir)
+		}
+	}
+}
+
+func runDescribe(path, specDir string, showParams bool, slots int) {
+	bundle, err := patch.LoadBundle(path)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Banks list their used slots only, followed by the free ones
+	slots = bankSlots(path, slots)
+	empty := initBase()
+	if slots > 0 {
+		if bundle.Len() > slots {
+			fmt.Printf("Error: %s holds %d presets, more than its %d slots\n", path, bundle.Len(), slots)
+			os.Exit(1)
+		}
+		fmt.Printf("Bank of %d slots in %s:\n", slots, path)
+	} else {
+		fmt.Printf("%d patches found in %s:\n", bundle.Len(), path)
+	}
+
+	specFor := decodeSpecs(specDir, "decoding")
+	for i, p := range bundle.Patches {
+		if slots > 0 && patch.IsEmptySlot(p, empty) {
+			continue
+		}
+		catName := p.Category()
+		if slots > 0 {
+			fmt.Printf("Slot %3d: %s (%s)\n", i+1, p.Name(), catName)
+		} else {
+			fmt.Printf("%2d: %s (%s)\n", i+1, p.Name(), catName)
+		}
+
+		if showParams {
+			describeParams(p, specFor(catName))
+		}
+	}
+	if slots > 0 {
+		// Slots past the end of an unpadded file are free as well
+		free := bundle.FreeSlots(empty)
+		for i := bundle.Len(); i < slots; i++ {
+			free = append(free, i)
+		}
+		fmt.Printf("Free slots: %d of %d", len(free), slots)
+		if len(free) > 0 {
+			fmt.Printf(" (%s)", patch.SlotRanges(free))
+		}
+		fmt.Println()
+	}
+	// Write descriptor file
+	if err := writeDescriptorFile(path, bundle, bankNotes(path, slots)...); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// describeParams prints every spec parameter of a single preset grouped by section
+func describeParams(p *patch.Patch, params patch.Spec) {
+	for _, section := range params.Sections() {
+		fmt.Printf("    [%s]\n", section.Name)
+		for _, pname := range section.Params {
+			val, err := p.Param(params, pname)
+			if err != nil {
+				fmt.Printf("      %-18s (no sysex offset in spec)\n", pname)
+				continue
+			}
+			if unit := params[pname].Unit; unit != "" {
+				fmt.Printf("      %-18s %3d %s\n", pname, val, unit)
+			} else {
+				fmt.Printf("      %-18s %3d\n", pname, val)
+			}
+		}
+	}
+}
+
+// runValidate checks SysEx structure and schema ranges of every preset in the
+// given files and reports all problems. It returns false if any file has errors.
+func runValidate(fileList, specDir string) bool {
+	files := collectSyxFiles(fileList)
+	if len(files) == 0 {
+		fmt.Println("Error: no valid sysex files found to validate")
+		return false
+	}
+
+	schema, err := patch.ParseSchema(schemaData)
+	if err != nil {
+		log.Fatalf("failed to parse JSON schema: %v", err)
+	}
+
+	specFor := decodeSpecs(specDir, "validation")
+	totalErrors, totalWarnings, invalidFiles := 0, 0, 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Warning: failed to read '%s': %v\n", path, err)
+			invalidFiles++
+			continue
+		}
+
+		problems := patch.ValidateStructure(data)
+
+		// Range checks only make sense on complete patches
+		n := len(data) / patch.Size
+		names := make(map[int]string, n)
+		for i := 0; i < n; i++ {
+			p, err := patch.New(data[i*patch.Size : (i+1)*patch.Size])
+			if err != nil {
+				continue
+			}
+			names[i] = p.Name()
+			problems = append(problems, patch.ValidateRanges(i, p, specFor(p.Category()), schema)...)
+		}
+
+		if len(problems) == 0 {
+			fmt.Printf("OK      %s (%d patches)\n", path, n)
+			continue
+		}
+
+		errCount, warnCount := 0, 0
+		for _, pr := range problems {
+			if pr.Structural() {
+				errCount++
+			} else {
+				warnCount++
+			}
+		}
+		status := "WARN"
+		if errCount > 0 {
+			status = "INVALID"
+			invalidFiles++
+		}
+		fmt.Printf("%-7s %s (%d patches, %d errors, %d warnings)\n", status, path, n, errCount, warnCount)
+		for _, pr := range problems {
+			level := "warning"
+			if pr.Structural() {
+				level = "error"
+			}
+			fmt.Printf("  %s: %v", level, pr)
+			if name, ok := names[pr.Patch]; ok {
+				fmt.Printf(" ('%s')", name)
+			}
+			fmt.Println()
+		}
+		totalErrors += errCount
+		totalWarnings += warnCount
+	}
+
+	fmt.Printf("Validated %d files: %d invalid, %d errors, %d warnings\n", len(files), invalidFiles, totalErrors, totalWarnings)
+	return invalidFiles == 0
+}
+
+// runLintSpecs checks every spec file of a directory and reports all problems
+// at once. It returns false if any error (not just warnings) was found.
+func runLintSpecs(specDir string) bool {
+	categories, err := specLoader.Categories(specDir)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(categories) == 0 {
+		fmt.Printf("Error: no spec files found in '%s'\n", specDir)
+		return false
+	}
+	schema, err := patch.ParseSchema(schemaData)
+	if err != nil {
+		log.Fatalf("failed to parse JSON schema: %v", err)
+	}
+
+	problems := make(map[string][]patch.SpecProblem)
+	specs := make(map[string]patch.Spec)
+	for _, category := range categories {
+		if _, ok := patch.CategoryCode(category); !ok {
+			problems[category] = append(problems[category], patch.SpecProblem{
+				Message: fmt.Sprintf("file name '%s.json' is not a category, the spec can never be used", category),
+			})
+		}
+		spec, rules, err := specLoader.Load(specDir, category)
+		if err != nil {
+			problems[category] = append(problems[category], patch.SpecProblem{Message: err.Error()})
+			continue
+		}
+		specs[category] = spec
+		problems[category] = append(problems[category], patch.LintSpec(spec, schema)...)
+		problems[category] = append(problems[category], patch.LintRules(rules, spec)...)
+	}
+	for category, set := range patch.LintSpecSet(specs) {
+		problems[category] = append(problems[category], set...)
+	}
+
+	totalErrors, totalWarnings, invalidFiles := 0, 0, 0
+	for _, category := range categories {
+		path := filepath.Join(specDir, category+".json")
+		if len(problems[category]) == 0 {
+			fmt.Printf("OK      %s\n", path)
+			continue
+		}
+
+		errCount, warnCount := 0, 0
+		for _, pr := range problems[category] {
+			if pr.Warning {
+				warnCount++
+			} else {
+				errCount++
+			}
+		}
+		status := "WARN"
+		if errCount > 0 {
+			status = "INVALID"
+			invalidFiles++
+		}
+		fmt.Printf("%-7s %s (%d errors, %d warnings)\n", status, path, errCount, warnCount)
+		for _, pr := range problems[category] {
+			level := "error"
+			if pr.Warning {
+				level = "warning"
+			}
+			fmt.Printf("  %s: %v\n", level, pr)
+		}
+		totalErrors += errCount
+		totalWarnings += warnCount
+	}
+	fmt.Printf("Linted %d spec files: %d invalid, %d errors, %d warnings\n", len(categories), invalidFiles, totalErrors, totalWarnings)
+	return invalidFiles == 0
+}
+
+// runDiff compares two presets or bundles parameter by parameter
+func runDiff(pathA, pathB, specDir string, byName bool) {
+	bundleA, err := patch.LoadBundle(pathA)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	bundleB, err := patch.LoadBundle(pathB)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	fmt.Printf("Comparing %s (%d presets) with %s (%d presets):\n", pathA, bundleA.Len(), pathB, bundleB.Len())
+
+	// Two single presets are always compared with each other, whatever their names
+	var pairs []patch.PresetPair
+	if byName && (bundleA.Len() > 1 || bundleB.Len() > 1) {
+		pairs = patch.AlignByName(bundleA, bundleB)
+	} else {
+		pairs = patch.AlignByPosition(bundleA, bundleB)
+	}
+
+	specFor := decodeSpecs(specDir, "diff")
+	added, removed, changed, unchanged := 0, 0, 0, 0
+	for _, pair := range pairs {
+		if pair.A < 0 {
+			p := bundleB.Patches[pair.B]
+			fmt.Printf("  + %2d: '%s' (%s) only in %s\n", pair.B+1, p.Name(), p.Category(), pathB)
+			added++
+			continue
+		}
+		if pair.B < 0 {
+			p := bundleA.Patches[pair.A]
+			fmt.Printf("  - %2d: '%s' (%s) only in %s\n", pair.A+1, p.Name(), p.Category(), pathA)
+			removed++
+			continue
+		}
+
+		a, b := bundleA.Patches[pair.A], bundleB.Patches[pair.B]
+		spec := specFor(a.Category())
+
+		diffs := patch.Diff(a, b, spec)
+		sameHeader := a.Name() == b.Name() && a.CategoryCode() == b.CategoryCode()
+		if len(diffs) == 0 && sameHeader {
+			unchanged++
+			continue
+		}
+		changed++
+
+		position := fmt.Sprintf("%d", pair.A+1)
+		if pair.A != pair.B {
+			position = fmt.Sprintf("%d->%d", pair.A+1, pair.B+1)
+		}
+		fmt.Printf("  ~ %2s: '%s' (%s) vs '%s' (%s): %d parameters differ\n",
+			position, a.Name(), a.Category(), b.Name(), b.Category(), len(diffs))
+		for _, d := range diffs {
+			unit := ""
+			if d.Unit != "" {
+				unit = " " + d.Unit
+			}
+			fmt.Printf("      %-20s %-18s %3d%s -> %d%s\n", d.Section, d.Name, d.A, unit, d.B, unit)
+		}
+	}
+
+	fmt.Printf("Summary: %d changed, %d unchanged, %d only in %s, %d only in %s\n",
+		changed, unchanged, removed, pathA, added, pathB)
+}
+
+// journalActions describes the recorded actions for --history and --undo
+var journalActions = map[string][2]string{
+	patch.ActionCreate: {"created", "remove"},
+	patch.ActionModify: {"modified", "restore"},
+	patch.ActionDelete: {"deleted", "restore"},
+	patch.ActionMkdir:  {"created directory", "remove directory (if empty)"},
+}
+
+// maxHistoryFiles limits the files listed per operation by --history
+const maxHistoryFiles = 10
+
+// runHistory lists the operations recorded in the journal, oldest first
+func runHistory() {
+	entries, err := journal.Entries()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No operations recorded in %s\n", displayPath(journal.Dir()))
+		return
+	}
+	fmt.Printf("%d operations recorded in %s (--undo reverts the last one):\n", len(entries), displayPath(journal.Dir()))
+	for _, e := range entries {
+		fmt.Printf("%4d  %s  %s\n", e.ID, e.Time.Format("2006-01-02 15:04:05"), e.Command)
+		for i, f := range e.Files {
+			if i == maxHistoryFiles {
+				fmt.Printf("        ... and %d more\n", len(e.Files)-i)
+				break
+			}
+			fmt.Printf("        %s %s\n", journalActions[f.Action][0], displayPath(f.Path))
+		}
+	}
+}
+
+// runUndo reverts the last operation recorded in the journal
+func runUndo() {
+	entries, err := journal.Entries()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("Nothing to undo, no operations recorded in %s\n", displayPath(journal.Dir()))
+		return
+	}
+	e := entries[len(entries)-1]
+	fmt.Printf("Undoing operation %d from %s: %s\n", e.ID, e.Time.Format("2006-01-02 15:04:05"), e.Command)
+	for i := len(e.Files) - 1; i >= 0; i-- {
+		f := e.Files[i]
+		fmt.Printf("  %s %s\n", journalActions[f.Action][1], displayPath(f.Path))
+	}
+	if dryRun {
+		dryRunSkipped = true
+		return
+	}
+	if err := journal.Undo(e); err != nil {
+		log.Fatalf("undo failed: %v", err)
+	}
+	fmt.Printf("Undo complete, %d operations left in the journal\n", len(entries)-1)
+}
+
+// displayPath shows journal paths relative to the current directory when inside it
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
+
+// runDedupe lists clusters of presets whose parameters are within threshold
+// of each other and optionally writes the presets without duplicates,
+// keeping the first preset of each cluster
+func runDedupe(fileList, specDir string, threshold float64, outPath string) {
+	files := collectSyxFiles(fileList)
+	if len(files) == 0 {
+		fmt.Println("Error: no valid sysex files found to dedupe")
+		os.Exit(1)
+	}
+
+	type entry struct {
+		patch    *patch.Patch
+		file     string
+		position int
+	}
+	var entries []entry
+	for _, path := range files {
+		bundle, err := patch.LoadBundle(path)
+		if err != nil {
+			fmt.Printf("Warning: skipping %v\n", err)
+			continue
+		}
+		for i, p := range bundle.Patches {
+			entries = append(entries, entry{patch: p, file: path, position: i + 1})
+		}
+	}
+	if len(entries) == 0 {
 		fmt.Println("Error: no presets found in any files")
+		os.Exit(1)
+	}
+
+	// Ranges come from the spec of each preset's category
+	specFor := decodeSpecs(specDir, "dedupe")
+	// Presets of different categories are compared under both specs so the
+	// distance does not depend on the order of the pair
+	distance := func(i, j int) float64 {
+		a, b := entries[i].patch, entries[j].patch
+		d := patch.Distance(a, b, specFor(a.Category()))
+		if a.Category() != b.Category() {
+			d = (d + patch.Distance(a, b, specFor(b.Category()))) / 2
+		}
+		return d
+	}
+
+	fmt.Printf("Comparing %d presets from %d files (threshold %g):\n", len(entries), len(files), threshold)
+	clusters := patch.Clusters(len(entries), threshold, distance)
+	// Clusters are joined transitively, so a preset is only dropped when it is
+	// within the threshold of a preset kept before it in the same cluster
+	drop := make(map[int]bool)
+	for n, cluster := range clusters {
+		fmt.Printf("Cluster %d (%d presets):\n", n+1, len(cluster))
+		var kept []int
+		for _, i := range cluster {
+			e := entries[i]
+			status := "keep"
+			nearest, best := -1, 0.0
+			for _, k := range kept {
+				if d := distance(k, i); nearest < 0 || d < best {
+					nearest, best = k, d
+				}
+			}
+			if nearest >= 0 && best <= threshold {
+				status = fmt.Sprintf("distance %.4f to %s:%d", best, entries[nearest].file, entries[nearest].position)
+				drop[i] = true
+			} else {
+				kept = append(kept, i)
+			}
+			fmt.Printf("  %s:%d '%s' (%s) %s\n", e.file, e.position, e.patch.Name(), e.patch.Category(), status)
+		}
+	}
+	fmt.Printf("Found %d clusters, %d of %d presets are near-duplicates\n", len(clusters), len(drop), len(entries))
+
+	if outPath == "" {
 		return
 	}
+	kept := &patch.Bundle{}
+	for i, e := range entries {
+		if !drop[i] {
+			kept.Append(e.patch)
+		}
+	}
+	if dir := filepath.Dir(outPath); dir != "." {
+		if err := makeDir(dir); err != nil {
+			log.Fatalf("failed to create directory '%s': %v", dir, err)
+		}
+	}
+	if err := saveBundle(kept, outPath); err != nil {
+		log.Fatalf("%v", err)
+	}
+	reportDone("Wrote %d presets without duplicates to %s\n", kept.Len(), outPath)
+	note := fmt.Sprintf("deduplicated from %s with threshold %g", fileList, threshold)
+	if err := writeDescriptorFile(outPath, kept, note); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
 
-	// Check for name conflicts and report them
-	nameConflicts := findNameConflicts(allPresets)
-	if len(nameConflicts) > 0 {
-		fmt.Printf("Warning: found %d duplicate preset names:\n", len(nameConflicts))
-		for name, count := range nameConflicts {
-			fmt.Printf("  '%s' appears %d times\n", name, count)
+// runSimilar ranks every preset found under dir by its distance to the
+// preset in targetPath and prints the closest ones
+func runSimilar(targetPath, dir, specDir string, top int) {
+	target, err := patch.Load(targetPath)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	spec, err := loadDecodeParams(specDir, target.Category())
+	if err != nil {
+		log.Fatalf("failed to load spec for similarity search: %v", err)
+	}
+
+	type match struct {
+		file     string
+		position int
+		patch    *patch.Patch
+		distance float64
+	}
+	var matches []match
+	files := 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
-		fmt.Println("Proceeding anyway - duplicates will be preserved")
+		if d.IsDir() || strings.ToLower(filepath.Ext(path)) != ".syx" {
+			return nil
+		}
+		// The target itself is always the closest match
+		if same, _ := sameFile(path, targetPath); same {
+			return nil
+		}
+		bundle, err := patch.LoadBundle(path)
+		if err != nil {
+			fmt.Printf("Warning: skipping %v\n", err)
+			return nil
+		}
+		files++
+		for i, p := range bundle.Patches {
+			matches = append(matches, match{file: path, position: i + 1, patch: p, distance: patch.Distance(target, p, spec)})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("failed to search '%s': %v", dir, err)
+	}
+	if len(matches) == 0 {
+		fmt.Printf("Error: no presets found under %s\n", dir)
+		os.Exit(1)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+	if top > len(matches) {
+		top = len(matches)
+	}
+	fmt.Printf("%d presets closest to '%s' (%s) among %d presets in %d files under %s:\n",
+		top, target.Name(), target.Category(), len(matches), files, dir)
+	for i, m := range matches[:top] {
+		fmt.Printf("%2d. %.4f  %s:%d '%s' (%s)\n", i+1, m.distance, m.file, m.position, m.patch.Name(), m.patch.Category())
+	}
+}
+
+// sameFile reports whether two paths designate the same file
+func sameFile(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(infoA, infoB), nil
+}
+
+// runExportJSON converts a single preset or bundle into a JSON document keyed by parameter names
+func runExportJSON(path, specDir, outPath string) {
+	bundle, err := patch.LoadBundle(path)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	specFor := decodeSpecs(specDir, "export")
+	docs := make([]patch.Document, bundle.Len())
+	for i, p := range bundle.Patches {
+		catName := p.Category()
+		if _, ok := patch.CategoryCode(catName); !ok {
+			log.Fatalf("preset %d '%s' has unknown category byte 0x%02X and cannot be exported", i+1, p.Name(), p.CategoryCode())
+		}
+		docs[i] = p.Document(specFor(catName))
+	}
+
+	var out []byte
+	if len(docs) == 1 {
+		out, err = json.MarshalIndent(docs[0], "", "  ")
+	} else {
+		out, err = json.MarshalIndent(patch.BundleDocument{Patches: docs}, "", "  ")
+	}
+	if err != nil {
+		log.Fatalf("failed to encode JSON: %v", err)
+	}
+
+	if outPath == "" {
+		outPath = strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+	}
+	if err := writeFile(outPath, append(out, '\n')); err != nil {
+		log.Fatalf("failed to write JSON file: %v", err)
+	}
+	reportDone("Exported %d preset(s) from %s to %s\n", len(docs), path, outPath)
+}
+
+// runImportJSON converts a JSON document produced by --export-json back to SysEx
+func runImportJSON(path, specDir, outPath string) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("failed to read JSON file: %v", err)
+	}
+
+	// A bundle document has a top-level "patches" array, a single preset does not
+	var probe struct {
+		Patches json.RawMessage `json:"patches"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		log.Fatalf("failed to parse JSON file: %v", err)
+	}
+	var docs []patch.Document
+	if probe.Patches != nil {
+		var bundleDoc patch.BundleDocument
+		if err := json.Unmarshal(raw, &bundleDoc); err != nil {
+			log.Fatalf("failed to parse JSON bundle: %v", err)
+		}
+		docs = bundleDoc.Patches
+	} else {
+		var doc patch.Document
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			log.Fatalf("failed to parse JSON preset: %v", err)
+		}
+		docs = []patch.Document{doc}
+	}
+	if len(docs) == 0 {
+		log.Fatalf("JSON file '%s' contains no presets", path)
+	}
+
+	schema, err := patch.ParseSchema(schemaData)
+	if err != nil {
+		log.Fatalf("failed to parse JSON schema: %v", err)
+	}
+
+	base := initBase()
+	specFor := decodeSpecs(specDir, "import")
+	bundle := &patch.Bundle{}
+	for i, doc := range docs {
+		spec := specFor(doc.Category)
+		p, err := patch.FromDocument(base, doc, spec)
+		if err != nil {
+			log.Fatalf("preset %d '%s': %v", i+1, doc.Name, err)
+		}
+		for _, pr := range patch.ValidateRanges(i, p, spec, schema) {
+			fmt.Printf("Warning: %v\n", pr)
+		}
+		bundle.Append(p)
+	}
+
+	if outPath == "" {
+		outPath = strings.TrimSuffix(path, filepath.Ext(path)) + ".syx"
+	}
+	if err := saveBundle(bundle, outPath); err != nil {
+		log.Fatalf("%v", err)
+	}
+	reportDone("Imported %d preset(s) from %s to %s\n", bundle.Len(), path, outPath)
+
+	if err := writeDescriptorFile(outPath, bundle); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// specLoader loads the spec directories, from the binary or the disk
+var specLoader = &patch.SpecLoader{Dir: specDirFS, Profile: extendsDir}
+
+// specDirFS returns the file system holding the files of a spec directory:
+// the binary when the directory is read embedded (see readsEmbedded), else
+// the disk
+func specDirFS(specDir string) (fs.FS, error) {
+	if readsEmbedded(specDir) {
+		return fs.Sub(specsFS, specDir)
+	}
+	return os.DirFS(specDir), nil
+}
+
+// loadParams loads the spec of a category, merged on top of the spec it
+// extends
+func loadParams(specDir, category string) (patch.Spec, error) {
+	params, _, err := specLoader.Load(specDir, category)
+	return params, err
+}
+
+// extendsDir returns the spec directory of a profile named in "extends"
+func extendsDir(profile, specDir string) string {
+	if dir, ok := builtinProfile(profile); ok {
+		return dir
 	}
+	if filepath.IsAbs(profile) {
+		return profile
+	}
+	return filepath.Join(filepath.Dir(specDir), profile)
+}
 
-	// Create output directory and files
-	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
-	bundleRaw := uniqueName(make(map[string]struct{}))
-	bundleName := strings.Title(strings.ToLower(bundleRaw))
-	subDir := filepath.Join("presets", bundleName)
-	err := os.MkdirAll(subDir, 0755)
-	if err != nil {
-		log.Fatalf("failed to create output directory: %v", err)
+// profileDir returns the spec directory holding a built-in profile
+func profileDir(name string) string {
+	if name == defaultProfile {
+		return "specs"
 	}
+	return "specs-" + name
+}
 
-	// Write combined bundle file
-	combined := fmt.Sprintf("%s_grouped_%s.syx", bundleName, timeStr)
-	combinedPath := filepath.Join(subDir, combined)
-	combinedData := concat(allPresets)
-	err = ioutil.WriteFile(combinedPath, combinedData, 0644)
-	if err != nil {
-		log.Fatalf("failed to write combined file: %v", err)
+// embeddedDirs holds the spec directories read from the binary even if they
+// exist on disk: the default specs and the profiles selected by name
+var embeddedDirs = map[string]bool{"specs": true}
+
+// builtinProfile returns the spec directory of a built-in profile and
+// selects it to be read from the binary
+func builtinProfile(name string) (string, bool) {
+	dir := profileDir(name)
+	if !isEmbeddedProfile(dir) {
+		return dir, false
 	}
+	embeddedDirs[dir] = true
+	return dir, true
+}
 
-	fmt.Printf("Wrote combined bundle with %d presets to %s\n", totalPresets, combinedPath)
+// readsEmbedded reports whether specs of specDir are read from the binary:
+// for the default specs and profiles selected by name, or when specDir only
+// exists as a built-in profile. Other directories are read from disk.
+func readsEmbedded(specDir string) bool {
+	if !isEmbeddedProfile(specDir) {
+		return false
+	}
+	if embeddedDirs[specDir] {
+		return true
+	}
+	_, err := os.Stat(specDir)
+	return err != nil
+}
 
-	// Write individual preset files
-	for _, preset := range allPresets {
-		// Extract name and category
-		name := strings.TrimRight(string(preset[8:16]), " \x00")
-		catByte := preset[16]
-		catName := getCategoryName(catByte)
+// isEmbeddedProfile reports whether specDir is a spec directory built into the binary
+func isEmbeddedProfile(specDir string) bool {
+	info, err := fs.Stat(specsFS, specDir)
+	return err == nil && info.IsDir()
+}
 
-		filename := fmt.Sprintf("%s_%s_%s.syx", catName, name, timeStr)
-		filepath := filepath.Join(subDir, filename)
+// profileNames returns the names of the built-in profiles, default first
+func profileNames() []string {
+	entries, _ := fs.ReadDir(specsFS, ".")
+	names := []string{defaultProfile}
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), "specs-") {
+			names = append(names, strings.TrimPrefix(e.Name(), "specs-"))
+		}
+	}
+	return names
+}
 
-		err = ioutil.WriteFile(filepath, preset, 0644)
+// runListProfiles prints every built-in profile with the categories it covers
+func runListProfiles() {
+	fmt.Println("Built-in spec profiles (use with --profile):")
+	for _, name := range profileNames() {
+		dir, _ := builtinProfile(name)
+		files, err := specLoader.Categories(dir)
 		if err != nil {
-			log.Printf("Warning: failed to write individual preset %s: %v", filename, err)
+			log.Fatalf("%v", err)
 		}
+		// Profiles may hold other specs (e.g. updated_Bass) next to their
+		// categories
+		var categories []string
+		for _, c := range files {
+			if _, ok := patch.CategoryCode(c); ok {
+				categories = append(categories, c)
+			}
+		}
+		fmt.Printf("  %-10s %2d categories: %s\n", name, len(categories), strings.Join(categories, ", "))
 	}
+}
 
-	fmt.Printf("Wrote %d individual preset files to %s\n", totalPresets, subDir)
-
-	// Write descriptor file
-	if err := writeDescriptorFile(combinedPath, combinedData); err != nil {
-		log.Printf("Warning: %v", err)
+// loadDecodeParams loads the spec used to decode a preset of the given category.
+// Sysex offsets are shared by every category, so when the category has no spec
+// file the first spec available in the directory is used instead. A spec file
+// that exists but cannot be loaded is reported.
+func loadDecodeParams(specDir, category string) (patch.Spec, error) {
+	params, err := loadParams(specDir, category)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return params, err
 	}
+	categories, listErr := specLoader.Categories(specDir)
+	if listErr != nil || len(categories) == 0 {
+		return nil, err
+	}
+	return loadParams(specDir, categories[0])
 }
 
-func findNameConflicts(presets [][]byte) map[string]int {
-	nameCounts := make(map[string]int)
-	conflicts := make(map[string]int)
-
-	for _, preset := range presets {
-		name := strings.TrimRight(string(preset[8:16]), " \x00")
-		nameCounts[name]++
-		if nameCounts[name] > 1 {
-			conflicts[name] = nameCounts[name]
+// decodeSpecs returns a lookup of the decode spec of each category, loading
+// specs lazily since bundles usually mix categories. Failing to load a spec
+// is fatal; purpose completes the error message.
+func decodeSpecs(specDir, purpose string) func(category string) patch.Spec {
+	cache := make(map[string]patch.Spec)
+	return func(category string) patch.Spec {
+		spec, ok := cache[category]
+		if !ok {
+			var err error
+			spec, err = loadDecodeParams(specDir, category)
+			if err != nil {
+				log.Fatalf("failed to load spec for %s: %v", purpose, err)
+			}
+			cache[category] = spec
 		}
+		return spec
 	}
+}
 
-	return conflicts
+// runGenerate creates or updates bundle and writes a .txt descriptor
+func runGenerate(count int, category string, catCode byte, base *patch.Patch, params patch.Spec, allowed map[string][]int, checker *patch.Checker, workers int, rng *rand.Rand, seed int64) {
+	patches, _ := generatePatches(count, catCode, base, params, allowed, checker, workers, rng)
+	if err := writeGeneratedPresets(patches, "bundle", rng, fmt.Sprintf("seed: %d", seed), "category: "+category, "base: "+base.Name()); err != nil {
+		log.Fatalf("%v", err)
+	}
 }
 
-func runSplit(path string) {
-	data, err := ioutil.ReadFile(path)
+// runMutate generates variations of an existing preset by perturbing each parameter
+func runMutate(path, specDir string, count int, amount float64, rng *rand.Rand, seed int64) {
+	parent, err := patch.Load(path)
 	if err != nil {
-		log.Fatalf("failed to read sysex file: %v", err)
+		log.Fatalf("%v", err)
 	}
+	fmt.Printf("Mutating '%s' (%s) into %d variations with amount %g\n", parent.Name(), parent.Category(), count, amount)
 
-	n := len(data) / patchSize
-	if n <= 1 {
-		fmt.Printf("File %s contains only %d preset, nothing to split.\n", path, n)
-		return
+	params, allowed, checker := loadGenerator(specDir, parent.Category(), true)
+	schemaProps, err := patch.ParseSchema(schemaData)
+	if err != nil {
+		log.Fatalf("failed to parse JSON schema: %v", err)
 	}
+	parent = clampToSchema(parent, params, schemaProps)
 
-	fmt.Printf("Splitting %d presets from %s into individual files:\n", n, path)
+	patches, rejected, err := patch.Variations(parent, count, count*1000, params, allowed, checker, amount, rng)
+	printRejections(rejected)
+	if err != nil {
+		log.Fatalf("%v, try a larger --amount or check the rules", err)
+	}
+	for i, p := range patches {
+		p.SetName(variantName(parent.Name(), i+1, count))
+	}
+	if err := writeGeneratedPresets(patches, "mutated", rng,
+		fmt.Sprintf("seed: %d", seed), "mutated from: "+path, fmt.Sprintf("amount: %g", amount)); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
 
-	// Create output directory based on input filename
-	baseName := strings.TrimSuffix(filepath.Base(path), ".syx")
-	outputDir := filepath.Join("presets", baseName+"_split")
-	err = os.MkdirAll(outputDir, 0755)
+// runMorph builds a bundle of presets interpolated between two presets
+func runMorph(pathA, pathB, specDir string, steps int, crossover float64, rng *rand.Rand) {
+	a, err := patch.Load(pathA)
 	if err != nil {
-		log.Fatalf("failed to create output directory: %v", err)
+		log.Fatalf("%v", err)
+	}
+	b, err := patch.Load(pathB)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	spec, err := loadDecodeParams(specDir, a.Category())
+	if err != nil {
+		log.Fatalf("failed to load spec for morph: %v", err)
 	}
 
-	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
+	fmt.Printf("Morphing '%s' (%s) into '%s' (%s) in %d steps, enum parameters switch at %g\n",
+		a.Name(), a.Category(), b.Name(), b.Category(), steps, crossover)
 
-	for i := 0; i < n; i++ {
-		// Extract preset data
-		off := i * patchSize
-		presetData := data[off : off+patchSize]
+	patches := make([]*patch.Patch, steps)
+	for i := 0; i < steps; i++ {
+		t := float64(i+1) / float64(steps+1)
+		p := patch.Interpolate(a, b, spec, t, crossover)
+		p.SetName(morphName(a.Name(), b.Name(), i+1, steps))
+		patches[i] = p
+		fmt.Printf("  %2d: %s (%s) t=%.2f\n", i+1, p.Name(), p.Category(), t)
+	}
 
-		// Extract name and category
-		name := strings.TrimRight(string(presetData[8:16]), " \x00")
-		catByte := presetData[16]
-		catName := getCategoryName(catByte)
+	if err := writeGeneratedPresets(patches, "morph", rng,
+		"morph: "+pathA+" -> "+pathB, fmt.Sprintf("steps: %d", steps), fmt.Sprintf("crossover: %g", crossover)); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
 
-		// Create filename: Category_PresetName_timestamp.syx
-		filename := fmt.Sprintf("%s_%s_%s.syx", catName, name, timeStr)
-		filepath := filepath.Join(outputDir, filename)
+// runLearnSpec writes a spec whose ranges cover the presets of a category
+// found in the given files, using the offsets of the existing spec
+func runLearnSpec(fileList, specDir, category string, catCode byte, outPath string) {
+	files := collectSyxFiles(fileList)
+	if len(files) == 0 {
+		fmt.Println("Error: no valid sysex files found to learn from")
+		os.Exit(1)
+	}
+	ref, err := loadDecodeParams(specDir, category)
+	if err != nil {
+		log.Fatalf("failed to load reference spec: %v", err)
+	}
 
-		// Write individual preset file
-		err = ioutil.WriteFile(filepath, presetData, 0644)
+	// The same sound often exists as a single file and inside a bundle, only
+	// count it once so it does not skew the most frequent values
+	var patches []*patch.Patch
+	seen := make(map[uint64]struct{})
+	duplicates := 0
+	for _, path := range files {
+		bundle, err := patch.LoadBundle(path)
 		if err != nil {
-			log.Printf("Warning: failed to write %s: %v", filepath, err)
+			fmt.Printf("Warning: skipping %v\n", err)
 			continue
 		}
+		for _, p := range bundle.Patches {
+			if p.CategoryCode() != catCode {
+				continue
+			}
+			key := p.ParamHash()
+			if _, ex := seen[key]; ex {
+				duplicates++
+				continue
+			}
+			seen[key] = struct{}{}
+			patches = append(patches, p)
+		}
+	}
+	if len(patches) == 0 {
+		fmt.Printf("Error: no %s presets found in %d files\n", category, len(files))
+		os.Exit(1)
+	}
+	fmt.Printf("Learning %s spec from %d presets in %d files (%d duplicates skipped)\n", category, len(patches), len(files), duplicates)
 
-		fmt.Printf("  %2d: %s (%s) -> %s\n", i+1, name, catName, filename)
+	learned := patch.LearnSpec(patches, ref)
+	if schemaProps, err := patch.ParseSchema(schemaData); err == nil {
+		outside := 0
+		for name, info := range learned {
+			if r, ok := schemaProps[name]; ok && (info.Min < r.Min || info.Max > r.Max) {
+				outside++
+			}
+		}
+		if outside > 0 {
+			fmt.Printf("Warning: %d learned ranges exceed the schema, generation clamps them to the schema ranges\n", outside)
+		}
 	}
 
-	fmt.Printf("Split complete. %d individual preset files written to %s\n", n, outputDir)
+	if outPath == "" {
+		outPath = category + ".json"
+	}
+	out, err := json.MarshalIndent(learned, "", "  ")
+	if err != nil {
+		log.Fatalf("failed to encode spec: %v", err)
+	}
+	if dir := filepath.Dir(outPath); dir != "." {
+		if err := makeDir(dir); err != nil {
+			log.Fatalf("failed to create directory '%s': %v", dir, err)
+		}
+	}
+	if err := writeFile(outPath, append(out, '\n')); err != nil {
+		log.Fatalf("failed to write spec '%s': %v", outPath, err)
+	}
+	reportDone("Wrote spec with %d parameters to %s\n", len(learned), outPath)
 }
 
-func runExtract(path, extractList string) {
-	data, err := ioutil.ReadFile(path)
+// runBreed creates offspring of two presets by section-level crossover
+func runBreed(pathA, pathB, specDir string, count int, mutation float64, rng *rand.Rand, seed int64) {
+	a, err := patch.Load(pathA)
 	if err != nil {
-		log.Fatalf("failed to read sysex file: %v", err)
+		log.Fatalf("%v", err)
 	}
-
-	n := len(data) / patchSize
-	if n <= 1 {
-		fmt.Printf("File %s contains only %d preset(s), use single preset editing instead.\n", path, n)
-		return
+	b, err := patch.Load(pathB)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
-
-	fmt.Printf("Extracting specific presets from %s (%d total presets):\n", path, n)
-
-	// Extract existing names for position/name lookup
-	existingNames := extractExistingNames(data)
-
-	// Parse extraction targets
-	targets := parseReplaceList(extractList, existingNames)
-
-	if len(targets) == 0 {
-		fmt.Println("Error: no valid extraction targets specified")
-		return
+	params, allowed, checker := loadGenerator(specDir, a.Category(), true)
+	schemaProps, err := patch.ParseSchema(schemaData)
+	if err != nil {
+		log.Fatalf("failed to parse JSON schema: %v", err)
 	}
 
-	// Warn for unmatched tokens
-	warnUnmatchedTokens(extractList, targets)
+	fmt.Printf("Breeding '%s' (%s) with '%s' (%s) into %d offspring\n", a.Name(), a.Category(), b.Name(), b.Category(), count)
+	a = clampToSchema(a, params, schemaProps)
+	b = clampToSchema(b, params, schemaProps)
 
-	// Create output directory based on input filename
-	baseName := strings.TrimSuffix(filepath.Base(path), ".syx")
-	outputDir := filepath.Join("presets", baseName+"_extracted")
-	err = os.MkdirAll(outputDir, 0755)
+	offspring, rejected, err := patch.Breed(a, b, count, count*1000, params, allowed, checker, mutation, rng)
+	patches := make([]*patch.Patch, len(offspring))
+	for i, o := range offspring {
+		o.Patch.SetName(morphName(a.Name(), b.Name(), i+1, count))
+		fmt.Printf("  %2d: %s takes from '%s': %s\n", i+1, o.Patch.Name(), b.Name(), strings.Join(o.FromB, ", "))
+		patches[i] = o.Patch
+	}
+	printRejections(rejected)
 	if err != nil {
-		log.Fatalf("failed to create output directory: %v", err)
+		log.Fatalf("%v, the parents may be too similar (try --mutation) or rules too strict", err)
 	}
+	if err := writeGeneratedPresets(patches, "offspring", rng,
+		fmt.Sprintf("seed: %d", seed), "parents: "+pathA+" x "+pathB, fmt.Sprintf("mutation: %g", mutation)); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
 
-	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
-	extractedCount := 0
-
-	// Extract each target preset
-	for _, target := range targets {
-		idx := target.index
-		if idx < 0 || idx >= n {
-			fmt.Printf("Warning: position %d out of range, skipping\n", idx+1)
+// clampToSchema returns a copy of p with every spec parameter outside the
+// schema range moved to the nearest allowed value, warning about each change
+func clampToSchema(p *patch.Patch, params patch.Spec, schemaProps patch.Schema) *patch.Patch {
+	out := p.Clone()
+	for _, pname := range params.Names() {
+		r, ok := schemaProps[pname]
+		if !ok {
 			continue
 		}
-
-		// Extract preset data
-		off := idx * patchSize
-		presetData := data[off : off+patchSize]
-
-		// Extract name and category
-		name := strings.TrimRight(string(presetData[8:16]), " \x00")
-		catByte := presetData[16]
-		catName := getCategoryName(catByte)
-
-		// Create filename: Category_PresetName_timestamp.syx
-		filename := fmt.Sprintf("%s_%s_%s.syx", catName, name, timeStr)
-		filePath := filepath.Join(outputDir, filename)
-
-		// Write individual preset file
-		err = ioutil.WriteFile(filePath, presetData, 0644)
+		v, err := out.Param(params, pname)
 		if err != nil {
-			log.Printf("Warning: failed to write %s: %v", filePath, err)
 			continue
 		}
-
-		fmt.Printf("  Extracted %2d: %s (%s) -> %s\n", idx+1, name, catName, filename)
-		extractedCount++
-	}
-
-	if extractedCount > 0 {
-		fmt.Printf("Extraction complete. %d preset files written to %s\n", extractedCount, outputDir)
-	} else {
-		fmt.Println("No presets were extracted.")
-		// Remove empty directory
-		os.Remove(outputDir)
+		clamped := v
+		if clamped < r.Min {
+			clamped = r.Min
+		}
+		if clamped > r.Max {
+			clamped = r.Max
+		}
+		if clamped != v {
+			fmt.Printf("Warning: '%s' has %s=%d outside schema range %d-%d, using %d\n", p.Name(), pname, v, r.Min, r.Max, clamped)
+			out.SetParam(params, pname, clamped)
+		}
 	}
+	return out
 }
 
-func runDescribe(path string) {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		log.Fatalf("failed to read sysex file: %v", err)
+// morphName combines the start of both preset names with a number
+func morphName(nameA, nameB string, i, count int) string {
+	digits := len(strconv.Itoa(count))
+	if digits < 2 {
+		digits = 2
 	}
-	n := len(data) / patchSize
-	fmt.Printf("%d patches found in %s:\n", n, path)
-	for i := 0; i < n; i++ {
-		off := i * patchSize
-		// extract name
-		name := strings.TrimRight(string(data[off+8:off+16]), " \x00")
-		// extract category
-		catByte := data[off+16]
-		catName := getCategoryName(catByte)
-		fmt.Printf("%2d: %s (%s)\n", i+1, name, catName)
+	half := (patch.NameLength - digits) / 2
+	if len(nameA) > half {
+		nameA = nameA[:half]
 	}
-	// Write descriptor file
-	if err := writeDescriptorFile(path, data); err != nil {
-		log.Printf("Warning: %v", err)
+	if len(nameB) > patch.NameLength-digits-len(nameA) {
+		nameB = nameB[:patch.NameLength-digits-len(nameA)]
 	}
+	return fmt.Sprintf("%s%s%0*d", nameA, nameB, digits, i)
 }
 
-func loadSpec(path, specDir string) ([]byte, error) {
-	if specDir == "specs" {
-		return fs.ReadFile(specsFS, filepath.ToSlash(path))
+// variantName derives the name of the i-th variation of a preset by replacing
+// the end of the base name with a zero-padded number
+func variantName(base string, i, count int) string {
+	digits := len(strconv.Itoa(count))
+	if digits < 2 {
+		digits = 2
+	}
+	if len(base) > patch.NameLength-digits {
+		base = base[:patch.NameLength-digits]
 	}
-	return os.ReadFile(path)
+	return fmt.Sprintf("%s%0*d", base, digits, i)
 }
 
-// runGenerate creates or updates bundle and writes a .txt descriptor
-func runGenerate(count int, category string, catCode byte, params map[string]ParamInfo, allowed map[string][]int, schema *gojsonschema.Schema) {
+// writeGeneratedPresets writes newly created presets: a single preset file, or
+// a bundle directory holding the combined file, individual presets and a
+// descriptor annotated with notes. It stops at the first file that cannot be
+// written.
+func writeGeneratedPresets(patches []*patch.Patch, kind string, rng *rand.Rand, notes ...string) error {
 	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
-	patches, names := generatePatches(count, catCode, params, allowed, schema)
+	bundle := &patch.Bundle{Patches: patches}
+	count := len(patches)
 
 	if count > 1 {
 		// bundle directory
-		bundleRaw := uniqueName(make(map[string]struct{}))
+		bundleRaw := uniqueName(rng, make(map[string]struct{}))
 		bundleName := strings.Title(strings.ToLower(bundleRaw))
 		subDir := filepath.Join("presets", bundleName)
-		os.MkdirAll(subDir, 0755)
+		if err := makeDir(subDir); err != nil {
+			return fmt.Errorf("failed to create directory '%s': %v", subDir, err)
+		}
 		// combined file (no category prefix for bundles)
-		combined := fmt.Sprintf("%s_bundle_%s.syx", bundleName, timeStr)
+		combined := fmt.Sprintf("%s_%s_%s.syx", bundleName, kind, timeStr)
 		combinedPath := filepath.Join(subDir, combined)
-		combinedData := concat(patches)
-		ioutil.WriteFile(combinedPath, combinedData, 0644)
-		fmt.Printf("Wrote combined %d presets to %s\n", count, combinedPath)
+		if err := saveBundle(bundle, combinedPath); err != nil {
+			return err
+		}
+		reportDone("Wrote combined %d presets to %s\n", count, combinedPath)
 
 		// individual patches
-		for i, p := range patches {
-			fname := fmt.Sprintf("%s_%s_%s.syx", category, names[i], timeStr)
-			ioutil.WriteFile(filepath.Join(subDir, fname), p, 0644)
+		for _, p := range patches {
+			fname := fmt.Sprintf("%s_%s_%s.syx", p.Category(), p.Name(), timeStr)
+			if err := writeFile(filepath.Join(subDir, fname), p.Bytes()); err != nil {
+				return fmt.Errorf("failed to write sysex file: %v", err)
+			}
 		}
-		fmt.Printf("Wrote %d individual presets to %s\n", count, subDir)
+		reportDone("Wrote %d individual presets to %s\n", count, subDir)
 
 		// descriptor text file using unified function
-		if err := writeDescriptorFile(combinedPath, combinedData); err != nil {
-			log.Printf("Warning: %v", err)
-		}
-	} else {
-		// single preset
-		path := filepath.Join("presets", fmt.Sprintf("%s_%s_%s.syx", category, names[0], timeStr))
-		ioutil.WriteFile(path, concat(patches), 0644)
-		fmt.Printf("Wrote 1 preset to %s\n", path)
+		return writeDescriptorFile(combinedPath, bundle, notes...)
+	}
+
+	// single preset
+	if err := makeDir("presets"); err != nil {
+		return fmt.Errorf("failed to create directory 'presets': %v", err)
 	}
+	p := patches[0]
+	path := filepath.Join("presets", fmt.Sprintf("%s_%s_%s.syx", p.Category(), p.Name(), timeStr))
+	if err := saveBundle(bundle, path); err != nil {
+		return err
+	}
+	reportDone("Wrote 1 preset to %s\n", path)
+	return nil
 }
 
 // runEdit supports index- and name-based replacement
@@ -976,20 +2667,9 @@ type replaceTarget struct {
 	name  string
 }
 
-// extractExistingNames extracts all preset names from sysex data
-func extractExistingNames(data []byte) []string {
-	n := len(data) / patchSize
-	names := make([]string, n)
-	for i := 0; i < n; i++ {
-		off := i*patchSize + 8
-		names[i] = strings.TrimRight(string(data[off:off+8]), " \x00")
-	}
-	return names
-}
-
 // PresetReplacement holds information about a preset replacement operation
 type PresetReplacement struct {
-	Data     []byte
+	Patch    *patch.Patch
 	Name     string
 	Category string
 }
@@ -1027,25 +2707,25 @@ func runEditWithFiles(editFile, replaceList, replaceWithFiles string) {
 }
 
 // runEdit replaces patches with randomly generated ones
-func runEdit(editFile, replaceList string, catCode byte, params map[string]ParamInfo, allowed map[string][]int, schema *gojsonschema.Schema) {
+func runEdit(editFile, replaceList string, catCode byte, base *patch.Patch, params patch.Spec, allowed map[string][]int, checker *patch.Checker, workers int, rng *rand.Rand, seed int64) {
 	// Create preset generator function for random generation
 	generateReplacements := func(count int, nameExclusions map[string]struct{}) ([]PresetReplacement, error) {
-		patches, names := generatePatchesWithExclusions(count, catCode, params, allowed, schema, nameExclusions)
+		patches, names := generatePatchesWithExclusions(count, catCode, base, params, allowed, checker, workers, nameExclusions, rng)
 
 		result := make([]PresetReplacement, count)
 		for i := 0; i < count; i++ {
-			categoryName := getCategoryName(catCode)
 			result[i] = PresetReplacement{
-				Data:     patches[i],
+				Patch:    patches[i],
 				Name:     names[i],
-				Category: categoryName,
+				Category: patch.CategoryName(catCode),
 			}
 		}
 		return result, nil
 	}
 
-	// Use common edit logic
-	runEditCommon(editFile, replaceList, generateReplacements)
+	// Use common edit logic, recording how to reproduce the replacements
+	note := fmt.Sprintf("replaced %s: seed: %d, category: %s, base: %s", replaceList, seed, patch.CategoryName(catCode), base.Name())
+	runEditCommon(editFile, replaceList, generateReplacements, note)
 }
 
 // loadReplacementFiles loads and validates single preset files
@@ -1065,32 +2745,18 @@ func loadReplacementFiles(replaceWithFiles string) ([]PresetReplacement, error)
 			continue
 		}
 
-		// Read file
-		fileData, err := ioutil.ReadFile(filePath)
+		// Read the single preset file
+		p, err := patch.Load(filePath)
 		if err != nil {
-			fmt.Printf("Warning: failed to read '%s': %v\n", filePath, err)
-			continue
-		}
-
-		// Check if it's a single preset
-		if len(fileData) != patchSize {
-			fmt.Printf("Warning: skipping '%s' - not a single preset file (size: %d bytes, expected: %d)\n",
-				filePath, len(fileData), patchSize)
+			fmt.Printf("Warning: skipping '%s' - %v\n", filePath, err)
 			continue
 		}
 
-		// Extract preset info
-		name := strings.TrimRight(string(fileData[8:16]), " \x00")
-		catByte := fileData[16]
-		category := getCategoryName(catByte)
-
-		replacement := PresetReplacement{
-			Data:     fileData,
-			Name:     name,
-			Category: category,
-		}
-
-		replacements = append(replacements, replacement)
+		replacements = append(replacements, PresetReplacement{
+			Patch:    p,
+			Name:     p.Name(),
+			Category: p.Category(),
+		})
 	}
 
 	return replacements, nil
@@ -1099,16 +2765,16 @@ func loadReplacementFiles(replaceWithFiles string) ([]PresetReplacement, error)
 // PresetGenerator is a function type that generates replacement presets
 type PresetGenerator func(count int, nameExclusions map[string]struct{}) ([]PresetReplacement, error)
 
-// runEditCommon contains the shared logic for both edit modes
-func runEditCommon(editFile, replaceList string, generateReplacements PresetGenerator) {
-	data, err := os.ReadFile(editFile)
+// runEditCommon contains the shared logic for both edit modes. notes are added
+// to those of the descriptor.
+func runEditCommon(editFile, replaceList string, generateReplacements PresetGenerator, notes ...string) {
+	bundle, err := patch.LoadBundle(editFile)
 	if err != nil {
-		log.Fatalf("failed to read sysex file: %v", err)
+		log.Fatalf("%v", err)
 	}
-	n := len(data) / patchSize
 
 	// extract existing names
-	existingNames := extractExistingNames(data)
+	existingNames := bundle.Names()
 
 	// parse replacement targets
 	targets := parseReplaceList(replaceList, existingNames)
@@ -1137,17 +2803,16 @@ func runEditCommon(editFile, replaceList string, generateReplacements PresetGene
 	checkNameConflicts(existingNames, targets, replacements)
 
 	// apply replacements
-	applyReplacements(data, targets, replacements, n)
+	applyReplacements(bundle, targets, replacements)
 
 	// write updated file
-	err = os.WriteFile(editFile, data, 0644)
-	if err != nil {
-		log.Fatalf("failed to write sysex file: %v", err)
+	if err := saveBundle(bundle, editFile); err != nil {
+		log.Fatalf("%v", err)
 	}
-	fmt.Printf("Successfully replaced %d presets in %s\n", len(targets), editFile)
+	reportDone("Successfully replaced %d presets in %s\n", len(targets), editFile)
 
 	// write descriptor and show completion message
-	updateDescriptorAndShowCompletion(editFile, data, n)
+	updateDescriptorAndShowCompletion(editFile, bundle, notes...)
 }
 
 // warnUnmatchedTokens warns about replacement tokens that couldn't be matched
@@ -1246,30 +2911,26 @@ func checkNameConflicts(existingNames []string, targets []replaceTarget, replace
 	}
 }
 
-// applyReplacements applies the replacement presets to the data
-func applyReplacements(data []byte, targets []replaceTarget, replacements []PresetReplacement, n int) {
+// applyReplacements applies the replacement presets to the bundle
+func applyReplacements(bundle *patch.Bundle, targets []replaceTarget, replacements []PresetReplacement) {
 	for i, target := range targets {
-		idx := target.index
-		if idx < 0 || idx >= n {
-			fmt.Printf("Warning: position %d out of range\n", idx+1)
-			continue
+		if err := bundle.Set(target.index, replacements[i].Patch.Clone()); err != nil {
+			fmt.Printf("Warning: %v\n", err)
 		}
-
-		off := idx * patchSize
-		copy(data[off:off+patchSize], replacements[i].Data)
 	}
 }
 
 // updateDescriptorAndShowCompletion handles final file updates and messaging
-func updateDescriptorAndShowCompletion(editFile string, data []byte, n int) {
-	// write descriptor using unified function
-	if err := writeDescriptorFile(editFile, data); err != nil {
+func updateDescriptorAndShowCompletion(editFile string, bundle *patch.Bundle, notes ...string) {
+	// Existing notes are kept, new ones are added after them
+	notes = append(readDescriptorNotes(descriptorPath(editFile)), notes...)
+	if err := writeDescriptorFile(editFile, bundle, notes...); err != nil {
 		log.Printf("Warning: %v", err)
 	}
 
 	// Update output message for consistency
-	if n > 1 {
-		fmt.Printf("Updated descriptor file: %s\n", strings.TrimSuffix(editFile, ".syx")+".txt")
+	if bundle.Len() > 1 {
+		reportDone("Updated descriptor file: %s\n", strings.TrimSuffix(editFile, ".syx")+".txt")
 	}
 }
 
@@ -1305,92 +2966,189 @@ func parseReplaceList(list string, existingNames []string) []replaceTarget {
 	return t
 }
 
-// writeDescriptorFile creates a descriptor text file for a sysex file
-func writeDescriptorFile(sysexPath string, data []byte) error {
-	n := len(data) / patchSize
-	if n <= 1 {
+// writeDescriptorFile creates a descriptor text file for a sysex file.
+// Notes are written as "# " comment lines above the preset list; without
+// notes, the comment lines of an existing descriptor are kept.
+func writeDescriptorFile(sysexPath string, bundle *patch.Bundle, notes ...string) error {
+	if bundle.Len() <= 1 {
 		return nil // Don't create descriptor for single patches
 	}
 
-	descPath := strings.TrimSuffix(sysexPath, ".syx") + ".txt"
-	f, err := os.Create(descPath)
+	descPath := descriptorPath(sysexPath)
+	if len(notes) == 0 {
+		notes = readDescriptorNotes(descPath)
+	}
+	var sb strings.Builder
+	for _, note := range notes {
+		sb.WriteString("# " + note + "\n")
+	}
+	sb.WriteString(bundle.Descriptor())
+	written, err := updateFile(descPath, []byte(sb.String()))
 	if err != nil {
 		return fmt.Errorf("failed to create descriptor file: %v", err)
 	}
-	defer f.Close()
+	if written {
+		fmt.Printf("Wrote descriptor to %s\n", descPath)
+	}
+	return nil
+}
 
-	w := bufio.NewWriter(f)
-	for i := 0; i < n; i++ {
-		// Extract name from sysex data
-		nameOff := i*patchSize + 8
-		name := strings.TrimRight(string(data[nameOff:nameOff+8]), " \x00")
+// descriptorPath returns the path of the descriptor file of a bundle
+func descriptorPath(sysexPath string) string {
+	return strings.TrimSuffix(sysexPath, ".syx") + ".txt"
+}
 
-		// Extract category
-		catByte := data[i*patchSize+16]
-		catName := getCategoryName(catByte)
-		fmt.Fprintf(w, "%2d: %s (%s)\n", i+1, name, catName)
+// readDescriptorNotes returns the comment lines of an existing descriptor file
+func readDescriptorNotes(descPath string) []string {
+	raw, err := os.ReadFile(descPath)
+	if err != nil {
+		return nil
 	}
-	w.Flush()
-	fmt.Printf("Wrote descriptor to %s\n", descPath)
-	return nil
+	var notes []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.HasPrefix(line, "#") {
+			notes = append(notes, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+		}
+	}
+	return notes
 }
 
 // generatePatchesWithExclusions generates patches avoiding excluded names
-func generatePatchesWithExclusions(count int, catCode byte, params map[string]ParamInfo, allowed map[string][]int, schema *gojsonschema.Schema, nameExclusions map[string]struct{}) ([][]byte, []string) {
-	patches := make([][]byte, 0, count)
-	names := make([]string, 0, count)
-	seen := make(map[string]struct{})
+func generatePatchesWithExclusions(count int, catCode byte, base *patch.Patch, params patch.Spec, allowed map[string][]int, checker *patch.Checker, workers int, nameExclusions map[string]struct{}, rng *rand.Rand) ([]*patch.Patch, []string) {
+	gen, err := patch.NewGenerator(base, catCode, params, allowed, checker, workers)
+	if err != nil {
+		log.Fatalf("failed to prepare generation: %v", err)
+	}
 
-	// Copy nameExclusions to seen to avoid collisions
+	// Copy nameExclusions to usedNames to avoid collisions
+	usedNames := make(map[string]struct{}, len(nameExclusions)+count)
 	for name := range nameExclusions {
-		seen[name] = struct{}{}
+		usedNames[name] = struct{}{}
+	}
+	names := make([]string, 0, count)
+	patches, rejected, err := gen.Generate(count, count*1000, rng.Int63(), func() string {
+		raw := uniqueNameWithExclusions(rng, usedNames)
+		names = append(names, raw)
+		// Add the new name to usedNames to prevent duplicates within this generation
+		usedNames[strings.ToLower(raw)] = struct{}{}
+		return raw
+	})
+	printRejections(rejected)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return patches, names
+}
+
+// printRejections reports why generated candidates were discarded, most
+// frequent reason first
+func printRejections(rejected map[string]int) {
+	if len(rejected) == 0 {
+		return
+	}
+	reasons := make([]string, 0, len(rejected))
+	total := 0
+	for reason, n := range rejected {
+		reasons = append(reasons, reason)
+		total += n
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if rejected[reasons[i]] != rejected[reasons[j]] {
+			return rejected[reasons[i]] > rejected[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%s: %d", reason, rejected[reason])
+	}
+	fmt.Printf("Rejected %d candidates (%s)\n", total, strings.Join(parts, ", "))
+}
+
+// lockParams removes locked parameters from allowed so generation keeps their
+// value from the base patch. With only set, every parameter it does not
+// select is locked instead.
+func lockParams(params patch.Spec, allowed map[string][]int, lock, only string) map[string][]int {
+	flagName, list := "--lock", lock
+	if only != "" {
+		flagName, list = "--only", only
+	}
+	var tokens []string
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	selected, unknown := params.Select(tokens)
+	if len(unknown) > 0 {
+		fmt.Printf("Error: unknown section or parameter in %s: %s\n", flagName, strings.Join(unknown, ", "))
+		os.Exit(1)
 	}
 
-	for len(patches) < count {
-		cfg := make(map[string]int)
-		for pname, vals := range allowed {
-			cfg[pname] = vals[rand.Intn(len(vals))]
+	inList := make(map[string]bool, len(selected))
+	for _, pname := range selected {
+		inList[pname] = true
+	}
+	result := make(map[string][]int, len(allowed))
+	for pname, vals := range allowed {
+		if inList[pname] == (only != "") {
+			result[pname] = vals
 		}
-		r, _ := schema.Validate(gojsonschema.NewGoLoader(cfg))
-		if !r.Valid() {
+	}
+	if len(result) == 0 {
+		fmt.Printf("Error: %s leaves no parameters to randomize\n", flagName)
+		os.Exit(1)
+	}
+	fmt.Printf("Randomizing %d of %d parameters, keeping the others from the base patch\n", len(result), len(allowed))
+	return result
+}
+
+// checkLockedValues makes sure every locked parameter of the base patch is
+// within the schema, otherwise no generated preset could pass validation
+func checkLockedValues(base *patch.Patch, params patch.Spec, allowed map[string][]int) {
+	schemaProps, err := patch.ParseSchema(schemaData)
+	if err != nil {
+		log.Fatalf("failed to parse JSON schema: %v", err)
+	}
+	var bad []string
+	for _, pname := range params.Names() {
+		if _, randomized := allowed[pname]; randomized {
 			continue
 		}
-		key := configKey(cfg)
-		if _, ex := seen[key]; ex {
+		v, err := base.Param(params, pname)
+		if err != nil {
 			continue
 		}
-		seen[key] = struct{}{}
-		raw := uniqueNameWithExclusions(seen)
-		names = append(names, raw)
-		patches = append(patches, buildPatch(raw, catCode, params, cfg))
-		// Add the new name to seen to prevent duplicates within this generation
-		seen[strings.ToLower(raw)] = struct{}{}
+		if r, ok := schemaProps[pname]; ok && (v < r.Min || v > r.Max) {
+			bad = append(bad, fmt.Sprintf("%s=%d (schema range %d-%d)", pname, v, r.Min, r.Max))
+		}
+	}
+	if len(bad) > 0 {
+		fmt.Printf("Error: base patch '%s' has locked values outside the schema: %s\n", base.Name(), strings.Join(bad, ", "))
+		os.Exit(1)
 	}
-	return patches, names
 }
 
 // generatePatches now uses the new exclusion-aware function with empty exclusions
-func generatePatches(count int, catCode byte, params map[string]ParamInfo, allowed map[string][]int, schema *gojsonschema.Schema) ([][]byte, []string) {
-	return generatePatchesWithExclusions(count, catCode, params, allowed, schema, make(map[string]struct{}))
+func generatePatches(count int, catCode byte, base *patch.Patch, params patch.Spec, allowed map[string][]int, checker *patch.Checker, workers int, rng *rand.Rand) ([]*patch.Patch, []string) {
+	return generatePatchesWithExclusions(count, catCode, base, params, allowed, checker, workers, make(map[string]struct{}), rng)
 }
 
 func printAvailableCategories() {
-	keys := make([]string, 0, len(categoryCodes))
-	for k := range categoryCodes {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	fmt.Println("Available categories:", strings.Join(keys, ", "))
+	fmt.Println("Available categories:", strings.Join(patch.Categories(), ", "))
 }
 
-func configKey(cfg map[string]int) string {
-	b, _ := json.Marshal(cfg)
-	return string(b)
+// randomAdjective picks a random adjective for preset and bundle names.
+// go-randomdata draws from its own global source, so it is pointed at rng
+// first to make names reproducible from a seed.
+func randomAdjective(rng *rand.Rand) string {
+	randomdata.CustomRand(rng)
+	return randomdata.Adjective()
 }
 
-func uniqueName(existing map[string]struct{}) string {
+func uniqueName(rng *rand.Rand, existing map[string]struct{}) string {
 	for {
-		n := randomdata.Adjective()
+		n := randomAdjective(rng)
 		if len(n) > 8 {
 			n = n[:8]
 		}
@@ -1400,10 +3158,19 @@ func uniqueName(existing map[string]struct{}) string {
 	}
 }
 
-// uniqueNameWithExclusions generates unique names avoiding exclusions
-func uniqueNameWithExclusions(existing map[string]struct{}) string {
-	for {
-		n := randomdata.Adjective()
+// uniqueNameWithExclusions generates unique names avoiding exclusions. Once
+// plain adjectives keep colliding (there are only about 500 of them), a
+// number is appended so large banks still get unique names.
+func uniqueNameWithExclusions(rng *rand.Rand, existing map[string]struct{}) string {
+	for tries := 0; ; tries++ {
+		n := randomAdjective(rng)
+		if tries >= 100 {
+			suffix := strconv.Itoa(rng.Intn(1000))
+			if len(n) > 8-len(suffix) {
+				n = n[:8-len(suffix)]
+			}
+			n += suffix
+		}
 		if len(n) > 8 {
 			n = n[:8]
 		}
@@ -1415,33 +3182,3 @@ func uniqueNameWithExclusions(existing map[string]struct{}) string {
 		}
 	}
 }
-
-func buildPatch(name string, catCode byte, params map[string]ParamInfo, cfg map[string]int) []byte {
-	patch := make([]byte, len(initPatch))
-	copy(patch, initPatch)
-	patch[0], patch[1], patch[2], patch[3], patch[4] = 0xF0, 0x00, 0x21, 0x22, 0x4D
-	patch[5], patch[6], patch[7] = 0x02, 0x03, 0x09
-	for i := 0; i < 8; i++ {
-		if i < len(name) {
-			patch[8+i] = name[i]
-		} else {
-			patch[8+i] = 0x20
-		}
-	}
-	patch[16] = catCode
-	patch[17], patch[18], patch[19] = 0, 0, 0
-	for pname, val := range cfg {
-		o := params[pname].SysexOffset
-		patch[o] = byte(val)
-	}
-	patch[len(patch)-1] = 0xF7
-	return patch
-}
-
-func concat(patches [][]byte) []byte {
-	var out []byte
-	for _, p := range patches {
-		out = append(out, p...)
-	}
-	return out
-}
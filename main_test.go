@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"micromonsta2-patch-tools/patch"
+)
+
+// testPreset describes a preset of a sort test; algo is OSC1_Algo (offset 20)
+type testPreset struct {
+	name, category string
+	algo           byte
+}
+
+// testPresets returns the presets in bundle order
+func testPresets(t *testing.T, specs ...testPreset) []PresetInfo {
+	t.Helper()
+	var presets []PresetInfo
+	for i, s := range specs {
+		data := make([]byte, patch.Size)
+		copy(data, patch.Header)
+		data[20] = s.algo
+		data[patch.Size-1] = patch.EndOfExclusive
+		p, err := patch.New(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, ok := patch.CategoryCode(s.category)
+		if !ok {
+			t.Fatalf("unknown category %s", s.category)
+		}
+		p.SetName(s.name)
+		p.SetCategory(code)
+		presets = append(presets, PresetInfo{Patch: p, Name: p.Name(), Category: p.Category(), CatCode: code, Index: i})
+	}
+	return presets
+}
+
+func TestSortKeys(t *testing.T) {
+	bundle := []testPreset{
+		{"Wobble", "Bass", 5},
+		{"acid", "Lead", 2},
+		{"Drift", "Pad", 9},
+		{"Bounce", "Bass", 2},
+		{"Choir", "Lead", 7},
+	}
+	tests := []struct {
+		sortBy string
+		want   []string
+	}{
+		{"name", []string{"acid", "Bounce", "Choir", "Drift", "Wobble"}},
+		{"-name", []string{"Wobble", "Drift", "Choir", "Bounce", "acid"}},
+		{"category", []string{"Wobble", "Bounce", "acid", "Choir", "Drift"}},
+		{"category,name", []string{"Bounce", "Wobble", "acid", "Choir", "Drift"}},
+		{"category:Pad/Lead", []string{"Drift", "acid", "Choir", "Wobble", "Bounce"}},
+		{"-position", []string{"Choir", "Bounce", "Drift", "acid", "Wobble"}},
+		{"osc1_algo", []string{"acid", "Bounce", "Wobble", "Choir", "Drift"}},
+		{"-OSC1_Algo,name", []string{"Drift", "Choir", "Wobble", "acid", "Bounce"}},
+	}
+	for _, tt := range tests {
+		keys, err := parseSortKeys(tt.sortBy, "specs")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sortBy, err)
+		}
+		presets := testPresets(t, bundle...)
+		sortPresets(presets, keys)
+		var got []string
+		for _, p := range presets {
+			got = append(got, p.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.sortBy, got, tt.want)
+		}
+	}
+}
+
+func TestSortKeysErrors(t *testing.T) {
+	for _, sortBy := range []string{"", "name,", "category:Nope", "Resonance42"} {
+		if _, err := parseSortKeys(sortBy, "specs"); err == nil {
+			t.Errorf("parseSortKeys(%q) succeeded, want error", sortBy)
+		}
+	}
+}
+
+func TestSortBankKeepsFreeSlots(t *testing.T) {
+	empty := initBase()
+	presets := testPresets(t, testPreset{"Wobble", "Bass", 0}, testPreset{"Acid", "Lead", 0})
+	for i := 0; i < 2; i++ {
+		presets = append(presets, PresetInfo{Patch: empty.Clone(), Name: empty.Name(), Category: empty.Category(), Index: len(presets)})
+	}
+	presets[1], presets[2] = presets[2], presets[1] // a free slot between used ones
+
+	keys, err := parseSortKeys("-name", "specs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	used, free := splitFreeSlots(presets, empty)
+	sortPresets(used, keys)
+	var got []string
+	for _, p := range append(used, free...) {
+		got = append(got, p.Name)
+	}
+	if want := []string{"Wobble", "Acid", "INIT", "INIT"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted bank = %v, want %v", got, want)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	journal = patch.OpenJournal(dir, "test")
+	dryRun = true
+	defer func() { dryRun, dryRunSkipped = false, false }()
+
+	existing := filepath.Join(dir, "a.syx")
+	if err := os.WriteFile(existing, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing identical content is no change, even in --dry-run mode
+	if err := writeFile(existing, []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	if dryRunSkipped {
+		t.Error("unchanged write reported as skipped")
+	}
+
+	if err := writeFile(existing, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(filepath.Join(dir, "b.syx"), []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := makeDir(filepath.Join(dir, "sub", "dir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeFile(existing); err != nil {
+		t.Fatal(err)
+	}
+	if !dryRunSkipped {
+		t.Error("skipped changes not reported")
+	}
+
+	if data, err := os.ReadFile(existing); err != nil || string(data) != "old" {
+		t.Errorf("%s = %q, %v; want it untouched", existing, data, err)
+	}
+	for _, path := range []string{"b.syx", "sub", patch.JournalDir} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+			t.Errorf("%s was created in --dry-run mode", path)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// bankNotePrefix starts the descriptor note declaring a bundle as a bank
+const bankNotePrefix = "bank: "
+
+// BankNote returns the descriptor note declaring a bank of the given size
+func BankNote(slots int) string {
+	return fmt.Sprintf("%s%d slots", bankNotePrefix, slots)
+}
+
+// ParseBankNote returns the bank size declared by a descriptor note
+func ParseBankNote(note string) (int, bool) {
+	if !strings.HasPrefix(note, bankNotePrefix) {
+		return 0, false
+	}
+	var slots int
+	if _, err := fmt.Sscanf(note[len(bankNotePrefix):], "%d slots", &slots); err != nil || slots <= 0 {
+		return 0, false
+	}
+	return slots, true
+}
+
+// IsEmptySlot reports whether p is an unused bank slot, i.e. holds exactly
+// the empty patch
+func IsEmptySlot(p, empty *Patch) bool {
+	return bytes.Equal(p.data, empty.data)
+}
+
+// Pad fills the bundle with copies of empty up to the given number of slots
+func (b *Bundle) Pad(slots int, empty *Patch) error {
+	if b.Len() > slots {
+		return fmt.Errorf("%d presets do not fit in a bank of %d slots", b.Len(), slots)
+	}
+	for b.Len() < slots {
+		b.Append(empty.Clone())
+	}
+	return nil
+}
+
+// FreeSlots returns the 0-based indices of the slots holding the empty patch
+func (b *Bundle) FreeSlots(empty *Patch) []int {
+	var free []int
+	for i, p := range b.Patches {
+		if IsEmptySlot(p, empty) {
+			free = append(free, i)
+		}
+	}
+	return free
+}
+
+// SlotRanges formats 0-based slot indices as 1-based ranges, e.g. "3, 7-128"
+func SlotRanges(slots []int) string {
+	var parts []string
+	for i := 0; i < len(slots); {
+		j := i
+		for j+1 < len(slots) && slots[j+1] == slots[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, fmt.Sprint(slots[i]+1))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", slots[i]+1, slots[j]+1))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
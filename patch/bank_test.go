@@ -0,0 +1,56 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBankNote(t *testing.T) {
+	if slots, ok := ParseBankNote(BankNote(128)); !ok || slots != 128 {
+		t.Errorf("ParseBankNote(BankNote(128)) = %d, %v", slots, ok)
+	}
+	for _, note := range []string{"seed: 42", "bank: 0 slots", "bank: many slots", "bank:"} {
+		if slots, ok := ParseBankNote(note); ok {
+			t.Errorf("ParseBankNote(%q) = %d, want no bank", note, slots)
+		}
+	}
+}
+
+func TestBankPad(t *testing.T) {
+	empty := namedPatch(t, "INIT")
+	b := namedBundle(t, "A", "B")
+	if err := b.Pad(5, empty); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 5 {
+		t.Fatalf("Len() = %d after Pad(5), want 5", b.Len())
+	}
+	if got := b.FreeSlots(empty); !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Errorf("FreeSlots() = %v, want [2 3 4]", got)
+	}
+	// Padding clones the empty patch
+	b.Patches[2].SetName("C")
+	if empty.Name() != "INIT" || IsEmptySlot(b.Patches[2], empty) {
+		t.Error("a padded slot shares its data with the empty patch")
+	}
+	if err := b.Pad(3, empty); err == nil {
+		t.Error("Pad(3) on 5 presets succeeded, want error")
+	}
+}
+
+func TestSlotRanges(t *testing.T) {
+	tests := []struct {
+		slots []int
+		want  string
+	}{
+		{nil, ""},
+		{[]int{0}, "1"},
+		{[]int{2, 6, 7, 8}, "3, 7-9"},
+		{[]int{0, 1, 3, 5, 6}, "1-2, 4, 6-7"},
+	}
+	for _, tt := range tests {
+		if got := SlotRanges(tt.slots); got != tt.want {
+			t.Errorf("SlotRanges(%v) = %q, want %q", tt.slots, got, tt.want)
+		}
+	}
+}
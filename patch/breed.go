@@ -0,0 +1,89 @@
+package patch
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Crossover returns a child of a and b that takes the parameters of the
+// sections named in fromB from b and everything else, including name,
+// category and bytes outside spec, from a.
+func Crossover(a, b *Patch, spec Spec, fromB []string) *Patch {
+	take := make(map[string]bool, len(fromB))
+	for _, section := range fromB {
+		take[section] = true
+	}
+
+	child := a.Clone()
+	for _, section := range spec.Sections() {
+		if !take[section.Name] {
+			continue
+		}
+		for _, name := range section.Params {
+			off, err := spec.Offset(name)
+			if err != nil {
+				continue
+			}
+			child.data[off] = b.data[off]
+		}
+	}
+	return child
+}
+
+// Offspring is a child of two patches with the sections it took from the
+// second parent
+type Offspring struct {
+	Patch *Patch
+	FromB []string
+}
+
+// Breed returns count distinct children of a and b. Each section of spec is
+// inherited as a whole from a randomly chosen parent, always mixing both
+// parents, then children are mutated by mutation (see Mutate) and must pass
+// checker. At most maxDraws children are drawn; the number of rejected
+// children per checker reason and "duplicate" is returned even when fewer
+// than count offspring could be bred.
+func Breed(a, b *Patch, count, maxDraws int, spec Spec, allowed map[string][]int, checker *Checker, mutation float64, rng *rand.Rand) ([]Offspring, map[string]int, error) {
+	sections := spec.Sections()
+	seen := map[uint64]struct{}{
+		a.ParamHash(): {},
+		b.ParamHash(): {},
+	}
+	offspring := make([]Offspring, 0, count)
+	rejected := make(map[string]int)
+	for draws := 0; len(offspring) < count; draws++ {
+		if draws >= maxDraws {
+			return offspring, rejected, fmt.Errorf("could only breed %d distinct offspring", len(offspring))
+		}
+
+		var fromA, fromB []string
+		for _, section := range sections {
+			if rng.Intn(2) == 0 {
+				fromA = append(fromA, section.Name)
+			} else {
+				fromB = append(fromB, section.Name)
+			}
+		}
+		// A child taking every section from one parent is just a copy of it
+		if len(sections) > 1 && (len(fromA) == 0 || len(fromB) == 0) {
+			continue
+		}
+
+		child := Crossover(a, b, spec, fromB)
+		if mutation > 0 {
+			child = Mutate(child, spec, allowed, mutation, rng)
+		}
+		if reason := checker.Check(child); reason != "" {
+			rejected[reason]++
+			continue
+		}
+		key := child.ParamHash()
+		if _, ex := seen[key]; ex {
+			rejected["duplicate"]++
+			continue
+		}
+		seen[key] = struct{}{}
+		offspring = append(offspring, Offspring{Patch: child, FromB: fromB})
+	}
+	return offspring, rejected, nil
+}
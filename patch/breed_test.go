@@ -0,0 +1,108 @@
+package patch
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// breedSpec has three sections of one parameter each
+var breedSpec = Spec{
+	"OSC1_Algo":  {Min: 0, Max: 127, SysexOffset: 20, SysexLength: 1, Scale: "enum", Section: "Oscillator 1"},
+	"FLT_Cutoff": {Min: 0, Max: 127, SysexOffset: 30, SysexLength: 1, Scale: "linear", Section: "Filter"},
+	"ENV1_Att":   {Min: 0, Max: 127, SysexOffset: 40, SysexLength: 1, Scale: "linear", Section: "ENV1"},
+}
+
+func breedParents(t *testing.T) (*Patch, *Patch) {
+	t.Helper()
+	a, _ := New(rawPatch())
+	b, _ := New(rawPatch())
+	a.SetName("Mother")
+	b.SetName("Father")
+	b.SetCategory(1)
+	for _, off := range []int{20, 30, 40} {
+		a.data[off], b.data[off] = 10, 90
+	}
+	a.data[50], b.data[50] = 1, 2 // outside the spec
+	return a, b
+}
+
+func TestCrossover(t *testing.T) {
+	a, b := breedParents(t)
+	tests := []struct {
+		fromB []string
+		want  [3]byte
+	}{
+		{nil, [3]byte{10, 10, 10}},
+		{[]string{"Filter"}, [3]byte{10, 90, 10}},
+		{[]string{"Oscillator 1", "ENV1"}, [3]byte{90, 10, 90}},
+		{[]string{"Oscillator 1", "Filter", "ENV1"}, [3]byte{90, 90, 90}},
+		{[]string{"Unknown"}, [3]byte{10, 10, 10}},
+	}
+	for _, tt := range tests {
+		child := Crossover(a, b, breedSpec, tt.fromB)
+		got := [3]byte{child.data[20], child.data[30], child.data[40]}
+		if got != tt.want {
+			t.Errorf("from B %v: got %v, want %v", tt.fromB, got, tt.want)
+		}
+		if child.Name() != "Mother" || child.Category() != "Bass" || child.data[50] != 1 {
+			t.Errorf("from B %v: name, category or bytes outside the spec not taken from a", tt.fromB)
+		}
+	}
+}
+
+func TestBreed(t *testing.T) {
+	a, b := breedParents(t)
+	checker, err := NewChecker(breedSpec, Schema{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Three sections give six mixed children
+	offspring, _, err := Breed(a, b, 6, 10000, breedSpec, nil, checker, 0, rand.New(rand.NewSource(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mixes []string
+	for _, o := range offspring {
+		if len(o.FromB) == 0 || len(o.FromB) == 3 {
+			t.Errorf("child takes every section from one parent: %v", o.FromB)
+		}
+		want := Crossover(a, b, breedSpec, o.FromB)
+		if want.ParamHash() != o.Patch.ParamHash() {
+			t.Errorf("child does not match the sections it took from b: %v", o.FromB)
+		}
+		mixes = append(mixes, string([]byte{o.Patch.data[20], o.Patch.data[30], o.Patch.data[40]}))
+	}
+	sort.Strings(mixes)
+	for i := 1; i < len(mixes); i++ {
+		if mixes[i] == mixes[i-1] {
+			t.Error("duplicate offspring")
+		}
+	}
+
+	// A seventh distinct child does not exist
+	offspring, rejected, err := Breed(a, b, 7, 1000, breedSpec, nil, checker, 0, rand.New(rand.NewSource(3)))
+	if err == nil || len(offspring) != 6 || rejected["duplicate"] == 0 {
+		t.Errorf("got %d offspring and error %v, want 6 and an error", len(offspring), err)
+	}
+
+	// Mutated children must still pass the checker
+	strict, err := NewChecker(breedSpec, Schema{"FLT_Cutoff": {Min: 0, Max: 95}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := map[string][]int{"FLT_Cutoff": valueRange(0, 127)}
+	offspring, rejected, err = Breed(a, b, 20, 10000, breedSpec, allowed, strict, 0.2, rand.New(rand.NewSource(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range offspring {
+		if reason := strict.Check(o.Patch); reason != "" {
+			t.Errorf("offspring fails the checker: %s", reason)
+		}
+	}
+	if rejected["schema"] == 0 {
+		t.Error("no mutated child above the schema range was rejected")
+	}
+}
@@ -0,0 +1,150 @@
+package patch
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Bundle is an ordered collection of patches stored as one SysEx file
+type Bundle struct {
+	Patches []*Patch
+}
+
+// ParseBundle splits raw SysEx data into patches. The data is validated
+// first and a ValidationErrors is returned if any patch is malformed.
+func ParseBundle(data []byte) (*Bundle, error) {
+	if errs := ValidateStructure(data); len(errs) > 0 {
+		return nil, ValidationErrors(errs)
+	}
+	n := len(data) / Size
+	b := &Bundle{Patches: make([]*Patch, 0, n)}
+	for i := 0; i < n; i++ {
+		off := i * Size
+		p, err := New(data[off : off+Size])
+		if err != nil {
+			return nil, err
+		}
+		b.Patches = append(b.Patches, p)
+	}
+	return b, nil
+}
+
+// LoadBundle reads a SysEx file holding one or more patches
+func LoadBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sysex file: %v", err)
+	}
+	b, err := ParseBundle(data)
+	if err != nil {
+		return nil, fmt.Errorf("file '%s': %v", path, err)
+	}
+	return b, nil
+}
+
+// Len returns the number of patches in the bundle
+func (b *Bundle) Len() int {
+	return len(b.Patches)
+}
+
+// Bytes returns the concatenated SysEx data of all patches
+func (b *Bundle) Bytes() []byte {
+	out := make([]byte, 0, len(b.Patches)*Size)
+	for _, p := range b.Patches {
+		out = append(out, p.data...)
+	}
+	return out
+}
+
+// Save writes the bundle to path
+func (b *Bundle) Save(path string) error {
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write sysex file: %v", err)
+	}
+	return nil
+}
+
+// Names returns the preset names in bundle order
+func (b *Bundle) Names() []string {
+	names := make([]string, len(b.Patches))
+	for i, p := range b.Patches {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Append adds patches at the end of the bundle
+func (b *Bundle) Append(patches ...*Patch) {
+	b.Patches = append(b.Patches, patches...)
+}
+
+// Insert adds a patch at index i (0-based), shifting later patches
+func (b *Bundle) Insert(i int, p *Patch) error {
+	if i < 0 || i > len(b.Patches) {
+		return fmt.Errorf("position %d out of range", i+1)
+	}
+	b.Patches = append(b.Patches, nil)
+	copy(b.Patches[i+1:], b.Patches[i:])
+	b.Patches[i] = p
+	return nil
+}
+
+// Remove deletes and returns the patch at index i (0-based)
+func (b *Bundle) Remove(i int) (*Patch, error) {
+	if i < 0 || i >= len(b.Patches) {
+		return nil, fmt.Errorf("position %d out of range", i+1)
+	}
+	p := b.Patches[i]
+	b.Patches = append(b.Patches[:i], b.Patches[i+1:]...)
+	return p, nil
+}
+
+// Set replaces the patch at index i (0-based)
+func (b *Bundle) Set(i int, p *Patch) error {
+	if i < 0 || i >= len(b.Patches) {
+		return fmt.Errorf("position %d out of range", i+1)
+	}
+	b.Patches[i] = p
+	return nil
+}
+
+// Descriptor returns the text listing written next to bundles
+func (b *Bundle) Descriptor() string {
+	var sb strings.Builder
+	for i, p := range b.Patches {
+		fmt.Fprintf(&sb, "%2d: %s (%s)\n", i+1, p.Name(), p.Category())
+	}
+	return sb.String()
+}
+
+// DescriptorEntry is a preset listed in a descriptor file
+type DescriptorEntry struct {
+	Name     string
+	Category string // empty when the line gives no category
+}
+
+// ParseDescriptor reads the preset list of a descriptor file in the format
+// written by Descriptor. Blank lines and '#' comments are skipped; the
+// position prefix and the category suffix are optional, so a hand-edited
+// list of names also works.
+func ParseDescriptor(text string) []DescriptorEntry {
+	var entries []DescriptorEntry
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, ":"); i > 0 && strings.Trim(line[:i], "0123456789 ") == "" {
+			line = strings.TrimSpace(line[i+1:])
+		}
+		var e DescriptorEntry
+		if open := strings.LastIndex(line, " ("); open >= 0 && strings.HasSuffix(line, ")") {
+			e.Category = line[open+2 : len(line)-1]
+			line = line[:open]
+		}
+		e.Name = strings.TrimSpace(line)
+		entries = append(entries, e)
+	}
+	return entries
+}
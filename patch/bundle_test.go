@@ -0,0 +1,114 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+// namedBundle returns a bundle of Lead patches with the given names
+func namedBundle(t *testing.T, names ...string) *Bundle {
+	t.Helper()
+	b := &Bundle{}
+	for _, name := range names {
+		b.Append(namedPatch(t, name))
+	}
+	return b
+}
+
+func namedPatch(t *testing.T, name string) *Patch {
+	t.Helper()
+	p, err := New(rawPatch())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetName(name)
+	p.SetCategory(CategoryCodes["Lead"])
+	return p
+}
+
+func TestBundleInsertRemove(t *testing.T) {
+	b := namedBundle(t, "A", "B", "C")
+	if err := b.Insert(0, namedPatch(t, "X")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Insert(2, namedPatch(t, "Y")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Insert(b.Len(), namedPatch(t, "Z")); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"X", "A", "Y", "B", "C", "Z"}; !reflect.DeepEqual(b.Names(), want) {
+		t.Fatalf("after Insert: %v, want %v", b.Names(), want)
+	}
+
+	p, err := b.Remove(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "Y" {
+		t.Errorf("Remove(2) returned %s, want Y", p.Name())
+	}
+	if _, err := b.Remove(b.Len() - 1); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"X", "A", "B", "C"}; !reflect.DeepEqual(b.Names(), want) {
+		t.Errorf("after Remove: %v, want %v", b.Names(), want)
+	}
+
+	for _, i := range []int{-1, b.Len() + 1} {
+		if err := b.Insert(i, namedPatch(t, "E")); err == nil {
+			t.Errorf("Insert(%d) succeeded on %d patches", i, b.Len())
+		}
+	}
+	for _, i := range []int{-1, b.Len()} {
+		if _, err := b.Remove(i); err == nil {
+			t.Errorf("Remove(%d) succeeded on %d patches", i, b.Len())
+		}
+		if err := b.Set(i, namedPatch(t, "E")); err == nil {
+			t.Errorf("Set(%d) succeeded on %d patches", i, b.Len())
+		}
+	}
+	if b.Len() != 4 {
+		t.Errorf("failed operations changed the bundle: %v", b.Names())
+	}
+}
+
+func TestBundleMove(t *testing.T) {
+	// --move is a Remove followed by an Insert at the target position
+	tests := []struct {
+		from, to int
+		want     []string
+	}{
+		{0, 2, []string{"B", "C", "A", "D"}},
+		{3, 0, []string{"D", "A", "B", "C"}},
+		{1, 1, []string{"A", "B", "C", "D"}},
+	}
+	for _, tt := range tests {
+		b := namedBundle(t, "A", "B", "C", "D")
+		p, err := b.Remove(tt.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Insert(tt.to, p); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(b.Names(), tt.want) {
+			t.Errorf("move %d to %d: %v, want %v", tt.from+1, tt.to+1, b.Names(), tt.want)
+		}
+	}
+}
+
+func TestParseDescriptor(t *testing.T) {
+	b := namedBundle(t, "Acid", "Wobble")
+	b.Patches[1].SetCategory(CategoryCodes["Bass"])
+	want := []DescriptorEntry{{"Acid", "Lead"}, {"Wobble", "Bass"}}
+	if got := ParseDescriptor(b.Descriptor()); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDescriptor(Descriptor()) = %v, want %v", got, want)
+	}
+
+	text := "# base: INIT\n\n 3: Pluck (Keys)\nHand Edit\n10:Drone 2\n  # seed: 42\n"
+	want = []DescriptorEntry{{"Pluck", "Keys"}, {"Hand Edit", ""}, {"Drone 2", ""}}
+	if got := ParseDescriptor(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDescriptor(%q) = %v, want %v", text, got, want)
+	}
+}
@@ -0,0 +1,49 @@
+package patch
+
+import "sort"
+
+// CategoryCodes maps category names to SysEx category byte values
+var CategoryCodes = map[string]byte{
+	"Bass":       0x00,
+	"Lead":       0x01,
+	"Pad":        0x02,
+	"Keys":       0x03,
+	"Organ":      0x04,
+	"String":     0x05,
+	"Brass":      0x06,
+	"Percussion": 0x07,
+	"Drone":      0x08,
+	"Noise":      0x09,
+	"SFX":        0x0A,
+	"Arp":        0x0B,
+	"Misc":       0x0C,
+	"User1":      0x0D,
+	"User2":      0x0E,
+	"User3":      0x0F,
+}
+
+// CategoryName returns the category name for a given byte code
+func CategoryName(code byte) string {
+	for name, c := range CategoryCodes {
+		if c == code {
+			return name
+		}
+	}
+	return "Unknown"
+}
+
+// CategoryCode returns the byte code for a category name
+func CategoryCode(name string) (byte, bool) {
+	code, ok := CategoryCodes[name]
+	return code, ok
+}
+
+// Categories returns all category names sorted alphabetically
+func Categories() []string {
+	keys := make([]string, 0, len(CategoryCodes))
+	for k := range CategoryCodes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package patch
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+)
+
+// Checker tests candidate patches against schema ranges and rules. Offsets
+// are resolved once, so checking a candidate only reads bytes.
+type Checker struct {
+	ranges []rangeCheck
+	rules  []ruleCheck
+}
+
+type rangeCheck struct {
+	offset   int
+	min, max int
+}
+
+type ruleCheck struct {
+	reason     string
+	cond, then *offsetCondition
+}
+
+type offsetCondition struct {
+	offsets []int
+	op      string
+	value   int
+}
+
+// NewChecker compiles the schema ranges of the parameters in spec and the
+// rules into a Checker
+func NewChecker(spec Spec, schema Schema, rules []Rule) (*Checker, error) {
+	c := &Checker{}
+	for _, name := range spec.Names() {
+		r, ok := schema[name]
+		if !ok {
+			continue
+		}
+		off, err := spec.Offset(name)
+		if err != nil {
+			return nil, err
+		}
+		c.ranges = append(c.ranges, rangeCheck{offset: off, min: r.Min, max: r.Max})
+	}
+	for _, r := range rules {
+		rc := ruleCheck{reason: "rule " + r.Name}
+		var err error
+		if rc.cond, err = compileCondition(r.cond, spec); err != nil {
+			return nil, fmt.Errorf("rule '%s': %v", r.Name, err)
+		}
+		if rc.then, err = compileCondition(r.then, spec); err != nil {
+			return nil, fmt.Errorf("rule '%s': %v", r.Name, err)
+		}
+		c.rules = append(c.rules, rc)
+	}
+	return c, nil
+}
+
+func compileCondition(cond *condition, spec Spec) (*offsetCondition, error) {
+	if cond == nil {
+		return nil, nil
+	}
+	oc := &offsetCondition{op: cond.op, value: cond.value}
+	for _, name := range cond.params {
+		off, err := spec.Offset(name)
+		if err != nil {
+			return nil, err
+		}
+		oc.offsets = append(oc.offsets, off)
+	}
+	return oc, nil
+}
+
+func (oc *offsetCondition) holds(data []byte) bool {
+	sum := 0
+	for _, off := range oc.offsets {
+		sum += int(data[off])
+	}
+	return compare(sum, oc.op, oc.value)
+}
+
+// Check returns why p is rejected: "schema" for a value outside its schema
+// range, "rule <name>" for the first violated rule, or "" if p passes
+func (c *Checker) Check(p *Patch) string {
+	for _, r := range c.ranges {
+		v := int(p.data[r.offset])
+		if v < r.min || v > r.max {
+			return "schema"
+		}
+	}
+	for _, r := range c.rules {
+		if r.cond == nil {
+			continue
+		}
+		if r.then == nil {
+			if !r.cond.holds(p.data) {
+				return r.reason
+			}
+		} else if r.cond.holds(p.data) && !r.then.holds(p.data) {
+			return r.reason
+		}
+	}
+	return ""
+}
+
+// ParamHash returns a compact key of the parameter bytes of p, used to detect
+// duplicate sounds regardless of name and category
+func (p *Patch) ParamHash() uint64 {
+	h := fnv.New64a()
+	h.Write(p.data[FirstParamOffset : LastParamOffset+1])
+	return h.Sum64()
+}
+
+// Sampler draws random values for a fixed list of parameters. Offsets and
+// allowed values are resolved once so filling a candidate does no lookups.
+type Sampler struct {
+	offsets []int
+	infos   []ParamInfo
+	values  [][]int
+}
+
+// NewSampler prepares a sampler for names, drawing each parameter with its
+// spec distribution over its allowed values. The order of names fixes the
+// order of random draws.
+func NewSampler(spec Spec, allowed map[string][]int, names []string) (*Sampler, error) {
+	s := &Sampler{}
+	for _, name := range names {
+		off, err := spec.Offset(name)
+		if err != nil {
+			return nil, err
+		}
+		vals := allowed[name]
+		if len(vals) == 0 {
+			return nil, fmt.Errorf("no allowed values for parameter '%s'", name)
+		}
+		s.offsets = append(s.offsets, off)
+		s.infos = append(s.infos, spec[name])
+		s.values = append(s.values, vals)
+	}
+	return s, nil
+}
+
+// Fill overwrites the sampled parameters of p with random values
+func (s *Sampler) Fill(p *Patch, rng *rand.Rand) {
+	for i, off := range s.offsets {
+		p.data[off] = byte(s.infos[i].Sample(s.values[i], rng))
+	}
+}
@@ -0,0 +1,87 @@
+package patch
+
+import "strings"
+
+// ParamDiff describes a parameter whose value differs between two patches
+type ParamDiff struct {
+	Name    string
+	Section string
+	Unit    string
+	A       int
+	B       int
+}
+
+// Diff compares two patches parameter by parameter using the offsets in
+// spec and returns the differing parameters in patch layout order
+func Diff(a, b *Patch, spec Spec) []ParamDiff {
+	var diffs []ParamDiff
+	for _, name := range spec.Names() {
+		va, err := a.Param(spec, name)
+		if err != nil {
+			continue
+		}
+		vb, _ := b.Param(spec, name)
+		if va != vb {
+			info := spec[name]
+			diffs = append(diffs, ParamDiff{Name: name, Section: info.Section, Unit: info.Unit, A: va, B: vb})
+		}
+	}
+	return diffs
+}
+
+// PresetPair aligns a preset of bundle A with a preset of bundle B by
+// 0-based index. A side set to -1 means the preset only exists in the other
+// bundle.
+type PresetPair struct {
+	A int
+	B int
+}
+
+// AlignByPosition pairs presets occupying the same position
+func AlignByPosition(a, b *Bundle) []PresetPair {
+	n := a.Len()
+	if b.Len() > n {
+		n = b.Len()
+	}
+	pairs := make([]PresetPair, n)
+	for i := 0; i < n; i++ {
+		pairs[i] = PresetPair{A: i, B: i}
+		if i >= a.Len() {
+			pairs[i].A = -1
+		}
+		if i >= b.Len() {
+			pairs[i].B = -1
+		}
+	}
+	return pairs
+}
+
+// AlignByName pairs presets with the same name (case-insensitive). Duplicate
+// names are matched in bundle order. Pairs follow the order of bundle A,
+// followed by presets only found in bundle B.
+func AlignByName(a, b *Bundle) []PresetPair {
+	byName := make(map[string][]int)
+	for i, p := range b.Patches {
+		key := strings.ToLower(p.Name())
+		byName[key] = append(byName[key], i)
+	}
+
+	matched := make(map[int]struct{})
+	var pairs []PresetPair
+	for i, p := range a.Patches {
+		key := strings.ToLower(p.Name())
+		if candidates := byName[key]; len(candidates) > 0 {
+			pairs = append(pairs, PresetPair{A: i, B: candidates[0]})
+			matched[candidates[0]] = struct{}{}
+			byName[key] = candidates[1:]
+			continue
+		}
+		pairs = append(pairs, PresetPair{A: i, B: -1})
+	}
+	for i := range b.Patches {
+		if _, ok := matched[i]; !ok {
+			pairs = append(pairs, PresetPair{A: -1, B: i})
+		}
+	}
+	return pairs
+}
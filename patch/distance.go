@@ -0,0 +1,80 @@
+package patch
+
+import (
+	"math"
+	"sort"
+)
+
+// Distance returns how different two patches sound, from 0 (same parameter
+// values) to 1. Each spec parameter contributes the difference of its values
+// as a fraction of the spec range for linear scales, or 0/1 for enums; the
+// result is the mean over all parameters. Names and categories are ignored.
+func Distance(a, b *Patch, spec Spec) float64 {
+	total, n := 0.0, 0
+	for _, name := range spec.Names() {
+		va, err := a.Param(spec, name)
+		if err != nil {
+			continue
+		}
+		vb, _ := b.Param(spec, name)
+		n++
+		if va == vb {
+			continue
+		}
+		info := spec[name]
+		if info.Scale != "linear" || info.Max <= info.Min {
+			total++
+			continue
+		}
+		total += math.Min(1, math.Abs(float64(va-vb))/float64(info.Max-info.Min))
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / float64(n)
+}
+
+// Clusters groups n patches whose pairwise distance is at most threshold,
+// joining groups transitively. Only groups of two or more patches are
+// returned, each listing indices in order, sorted by their first index.
+func Clusters(n int, threshold float64, distance func(i, j int) float64) [][]int {
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if find(i) == find(j) || distance(i, j) > threshold {
+				continue
+			}
+			// The smaller root wins so each group is keyed by its first index
+			ri, rj := find(i), find(j)
+			if rj < ri {
+				ri, rj = rj, ri
+			}
+			parent[rj] = ri
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := 0; i < n; i++ {
+		root := find(i)
+		groups[root] = append(groups[root], i)
+	}
+	var clusters [][]int
+	for _, g := range groups {
+		if len(g) > 1 {
+			clusters = append(clusters, g)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i][0] < clusters[j][0] })
+	return clusters
+}
@@ -0,0 +1,62 @@
+package patch
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestClusters(t *testing.T) {
+	tests := []struct {
+		name      string
+		points    []float64
+		threshold float64
+		want      [][]int
+	}{
+		{"none", []float64{0, 10, 20}, 1, nil},
+		{"pair", []float64{0, 10, 10.5}, 1, [][]int{{1, 2}}},
+		{"exact threshold", []float64{0, 1}, 1, [][]int{{0, 1}}},
+		{"chain joins transitively", []float64{0, 0.8, 1.6, 2.4}, 1, [][]int{{0, 1, 2, 3}}},
+		{"two groups", []float64{0, 5, 0.5, 5.5, 9}, 1, [][]int{{0, 2}, {1, 3}}},
+		{"zero threshold", []float64{3, 3, 4, 3}, 0, [][]int{{0, 1, 3}}},
+		{"empty", nil, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Clusters(len(tt.points), tt.threshold, func(i, j int) float64 {
+				return math.Abs(tt.points[i] - tt.points[j])
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	spec := Spec{
+		"Cutoff": {Min: 0, Max: 100, SysexOffset: 20, SysexLength: 1, Scale: "linear"},
+		"Algo":   {Min: 0, Max: 30, SysexOffset: 21, SysexLength: 1, Scale: "enum"},
+	}
+	tests := []struct {
+		name string
+		a, b [2]byte
+		want float64
+	}{
+		{"same", [2]byte{50, 3}, [2]byte{50, 3}, 0},
+		{"linear", [2]byte{0, 3}, [2]byte{50, 3}, 0.25},
+		{"enum", [2]byte{50, 3}, [2]byte{50, 4}, 0.5},
+		{"capped", [2]byte{0, 3}, [2]byte{127, 4}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := New(rawPatch())
+			b, _ := New(rawPatch())
+			copy(a.data[20:], tt.a[:])
+			copy(b.data[20:], tt.b[:])
+			if got := Distance(a, b, spec); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
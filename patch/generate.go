@@ -0,0 +1,196 @@
+package patch
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// candidateBatch is the number of candidates drawn per job of the worker pool
+const candidateBatch = 256
+
+// AllowedValues returns the values generation may draw for each spec
+// parameter: its spec range clamped to the schema range. Parameters whose
+// ranges do not overlap get no values and are described in disjoint.
+func AllowedValues(spec Spec, schema Schema) (allowed map[string][]int, disjoint []string) {
+	allowed = make(map[string][]int, len(spec))
+	for name, info := range spec {
+		lo, hi := info.Min, info.Max
+		if r, ok := schema[name]; ok {
+			if r.Min > lo {
+				lo = r.Min
+			}
+			if r.Max < hi {
+				hi = r.Max
+			}
+			if hi < lo {
+				disjoint = append(disjoint, fmt.Sprintf("%s (spec %d..%d, schema %d..%d)", name, info.Min, info.Max, r.Min, r.Max))
+				continue
+			}
+		}
+		vals := make([]int, 0, hi-lo+1)
+		for v := lo; v <= hi; v++ {
+			vals = append(vals, v)
+		}
+		allowed[name] = vals
+	}
+	sort.Strings(disjoint)
+	return allowed, disjoint
+}
+
+// Candidate is a drawn patch with the reason the checker rejected it, empty
+// when it passed
+type Candidate struct {
+	Patch  *Patch
+	Reason string
+}
+
+// Generator draws random patches of one category on a pool of workers
+type Generator struct {
+	template *Patch
+	sampler  *Sampler
+	checker  *Checker
+	workers  int
+}
+
+// NewGenerator prepares generation from base: the parameters of allowed are
+// drawn in spec order from their allowed values, every other byte is taken
+// from base. Drawn patches are tested with checker.
+func NewGenerator(base *Patch, catCode byte, spec Spec, allowed map[string][]int, checker *Checker, workers int) (*Generator, error) {
+	// Locked parameters keep the value of the template, which the checker
+	// still tests against the schema
+	template, err := Build(base, "", catCode, spec, nil)
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	for _, name := range spec.Names() {
+		if _, ok := allowed[name]; ok {
+			order = append(order, name)
+		}
+	}
+	sampler, err := NewSampler(spec, allowed, order)
+	if err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return &Generator{template: template, sampler: sampler, checker: checker, workers: workers}, nil
+}
+
+// Generate returns count distinct patches passing the checker, drawing at
+// most maxDraws candidates, and named by name in draw order. The number of
+// rejected candidates per checker reason and "duplicate" is returned even
+// when fewer than count patches could be generated.
+func (g *Generator) Generate(count, maxDraws int, seed int64, name func() string) ([]*Patch, map[string]int, error) {
+	patches := make([]*Patch, 0, count)
+	seen := make(map[uint64]struct{})
+	rejected := make(map[string]int)
+	g.Draw(seed, maxDraws, func(c Candidate) bool {
+		if c.Reason != "" {
+			rejected[c.Reason]++
+			return true
+		}
+		key := c.Patch.ParamHash()
+		if _, ex := seen[key]; ex {
+			rejected["duplicate"]++
+			return true
+		}
+		seen[key] = struct{}{}
+		c.Patch.SetName(name())
+		patches = append(patches, c.Patch)
+		return len(patches) < count
+	})
+	if len(patches) < count {
+		return patches, rejected, fmt.Errorf("could only generate %d distinct presets, too few parameter values left to randomize or rules too strict", len(patches))
+	}
+	return patches, rejected, nil
+}
+
+type candidateJob struct {
+	index int
+	seed  int64
+	size  int
+}
+
+type candidateResult struct {
+	index      int
+	candidates []Candidate
+}
+
+// Draw draws up to total candidates and hands them to accept in draw order
+// until it returns false. Every job of candidateBatch candidates has its own
+// random source seeded from seed, and results are reordered by job, so the
+// candidates only depend on seed, never on the number of workers or their
+// scheduling.
+func (g *Generator) Draw(seed int64, total int, accept func(Candidate) bool) {
+	jobs := make(chan candidateJob)
+	results := make(chan candidateResult, g.workers)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(jobs)
+		seeds := rand.New(rand.NewSource(seed))
+		for i := 0; i*candidateBatch < total; i++ {
+			job := candidateJob{index: i, seed: seeds.Int63(), size: candidateBatch}
+			if rest := total - i*candidateBatch; rest < job.size {
+				job.size = rest
+			}
+			select {
+			case jobs <- job:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < g.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				rng := rand.New(rand.NewSource(job.seed))
+				res := candidateResult{index: job.index, candidates: make([]Candidate, job.size)}
+				for i := range res.candidates {
+					p := g.template.Clone()
+					g.sampler.Fill(p, rng)
+					res.candidates[i] = Candidate{Patch: p, Reason: g.checker.Check(p)}
+				}
+				select {
+				case results <- res:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]candidateResult)
+	next := 0
+	for res := range results {
+		pending[res.index] = res
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			for _, c := range ready.candidates {
+				if !accept(c) {
+					close(done)
+					for range results {
+					}
+					return
+				}
+			}
+		}
+	}
+}
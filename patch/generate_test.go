@@ -0,0 +1,138 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// BenchmarkGenerate measures drawing, checking and deduplicating Bass
+// candidates on one worker and on one worker per CPU
+func BenchmarkGenerate(b *testing.B) {
+	gen := func(workers int) *Generator {
+		rawSpec, err := os.ReadFile("../specs/Bass.json")
+		if err != nil {
+			b.Fatal(err)
+		}
+		spec, err := ParseSpec(rawSpec)
+		if err != nil {
+			b.Fatal(err)
+		}
+		rawSchema, err := os.ReadFile("../micromonsta_patch_schema.json")
+		if err != nil {
+			b.Fatal(err)
+		}
+		schema, err := ParseSchema(rawSchema)
+		if err != nil {
+			b.Fatal(err)
+		}
+		base, err := Load("../P292_Init.syx")
+		if err != nil {
+			b.Fatal(err)
+		}
+		allowed, _ := AllowedValues(spec, schema)
+		checker, err := NewChecker(spec, schema, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		g, err := NewGenerator(base, 0, spec, allowed, checker, workers)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return g
+	}
+
+	counts := []int{1}
+	if n := runtime.NumCPU(); n > 1 {
+		counts = append(counts, n)
+	}
+	for _, workers := range counts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			g := gen(workers)
+			b.ResetTimer()
+			n := 0
+			g.Draw(1, b.N, func(c Candidate) bool {
+				n++
+				return true
+			})
+			if n != b.N {
+				b.Fatalf("drew %d candidates, want %d", n, b.N)
+			}
+		})
+	}
+}
+
+func TestGenerateLocked(t *testing.T) {
+	base, _ := New(rawPatch())
+	base.data[21] = 5 // Algo
+	spec := mutateSpec
+	selected, unknown := spec.Select([]string{"cutoff"})
+	if len(unknown) > 0 {
+		t.Fatalf("unknown %v", unknown)
+	}
+	allowed := map[string][]int{}
+	for _, name := range selected {
+		allowed[name] = valueRange(spec[name].Min, spec[name].Max)
+	}
+	checker, err := NewChecker(spec, Schema{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := NewGenerator(base, 2, spec, allowed, checker, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patches, _, err := gen.Generate(20, 20000, 1, func() string { return "x" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range patches {
+		if algo, _ := p.Param(spec, "Algo"); algo != 5 {
+			t.Errorf("locked Algo is %d, want the base value 5", algo)
+		}
+		if p.Category() != "Pad" {
+			t.Errorf("category %s, want Pad", p.Category())
+		}
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	allowed := map[string][]int{
+		"Cutoff": valueRange(0, 100),
+		"Algo":   valueRange(0, 9),
+	}
+	checker, err := NewChecker(mutateSpec, Schema{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generate := func(workers int) []*Patch {
+		gen, err := NewGenerator(mutateParent(t, 0, 0), 1, mutateSpec, allowed, checker, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		patches, _, err := gen.Generate(50, 5000, 42, func() string {
+			n++
+			return fmt.Sprintf("P%d", n)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return patches
+	}
+
+	want := generate(1)
+	for _, workers := range []int{2, 8} {
+		got := generate(workers)
+		if len(got) != len(want) {
+			t.Fatalf("workers=%d generated %d patches, want %d", workers, len(got), len(want))
+		}
+		for i := range want {
+			if !bytes.Equal(got[i].Bytes(), want[i].Bytes()) {
+				t.Errorf("workers=%d: patch %d differs from workers=1", workers, i+1)
+			}
+		}
+	}
+}
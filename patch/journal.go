@@ -0,0 +1,267 @@
+package patch
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// JournalDir is the directory of a library holding its operation journal
+const JournalDir = ".mm2-journal"
+
+// Journal actions recorded for files and directories
+const (
+	ActionCreate = "create"
+	ActionModify = "modify"
+	ActionDelete = "delete"
+	ActionMkdir  = "mkdir"
+)
+
+// JournalFile is a file or directory changed by an operation, with the state
+// it had before the operation
+type JournalFile struct {
+	Path    string `json:"path"`
+	Action  string `json:"action"`
+	Existed bool   `json:"existed,omitempty"`
+	Prior   string `json:"prior,omitempty"` // entry file holding the previous content
+}
+
+// JournalEntry is one recorded operation, i.e. one command run
+type JournalEntry struct {
+	ID      int           `json:"id"`
+	Time    time.Time     `json:"time"`
+	Command string        `json:"command"`
+	Files   []JournalFile `json:"-"` // in recording order, one per path
+
+	dir string
+}
+
+// Journal records the prior state of every file an operation changes, so
+// that the operation can be undone. Entries are only written once the
+// operation changes something.
+type Journal struct {
+	root     string
+	command  string
+	entry    *JournalEntry
+	recorded map[string]bool
+}
+
+// LibraryRoot returns the library holding dir: the nearest directory from dir
+// upwards that has a journal or a presets directory, else dir itself
+func LibraryRoot(dir string) (string, error) {
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := start; ; d = filepath.Dir(d) {
+		for _, marker := range []string{JournalDir, "presets"} {
+			if info, err := os.Stat(filepath.Join(d, marker)); err == nil && info.IsDir() {
+				return d, nil
+			}
+		}
+		if filepath.Dir(d) == d {
+			return start, nil
+		}
+	}
+}
+
+// OpenJournal returns the journal of the library in root
+func OpenJournal(root, command string) *Journal {
+	return &Journal{root: filepath.Join(root, JournalDir), command: command, recorded: make(map[string]bool)}
+}
+
+// Dir returns the directory holding the journal
+func (j *Journal) Dir() string {
+	return j.root
+}
+
+// Record runs change, which writes or deletes path (action ActionModify or
+// ActionDelete; a missing file is recorded as created) or creates it as a
+// directory (ActionMkdir), and saves the state path had before. Nothing is
+// recorded when change fails.
+func (j *Journal) Record(path, action string, change func() error) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	// Once recorded, the prior state is already saved and only the latest
+	// action matters
+	f := JournalFile{Path: abs, Action: action}
+	var prior []byte
+	if !j.recorded[abs] && action != ActionMkdir {
+		data, err := os.ReadFile(abs)
+		switch {
+		case err == nil:
+			f.Existed, prior = true, data
+		case errors.Is(err, fs.ErrNotExist):
+			f.Action = ActionCreate
+		default:
+			return fmt.Errorf("failed to record '%s' in journal: %v", path, err)
+		}
+	}
+	if err := change(); err != nil {
+		return err
+	}
+
+	if err := j.begin(); err != nil {
+		return err
+	}
+	if f.Existed {
+		f.Prior = strconv.Itoa(len(j.recorded)+1) + ".prior"
+		if err := os.WriteFile(filepath.Join(j.entry.dir, f.Prior), prior, 0644); err != nil {
+			return fmt.Errorf("failed to record '%s' in journal: %v", path, err)
+		}
+	}
+	j.recorded[abs] = true
+	return j.appendFile(f)
+}
+
+// begin creates the entry of the current operation on its first change
+func (j *Journal) begin() error {
+	if j.entry != nil {
+		return nil
+	}
+	entries, err := j.Entries()
+	if err != nil {
+		return err
+	}
+	id := 1
+	if len(entries) > 0 {
+		id = entries[len(entries)-1].ID + 1
+	}
+	e := &JournalEntry{ID: id, Time: time.Now(), Command: j.command, dir: filepath.Join(j.root, fmt.Sprintf("%06d", id))}
+	if err := os.MkdirAll(e.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create journal entry: %v", err)
+	}
+	raw, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(e.dir, "entry.json"), append(raw, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to create journal entry: %v", err)
+	}
+	j.entry = e
+	return nil
+}
+
+// appendFile adds a line to the file list of the current entry
+func (j *Journal) appendFile(f JournalFile) error {
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(filepath.Join(j.entry.dir, "files.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	defer out.Close()
+	if _, err := out.Write(append(raw, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	return nil
+}
+
+// Entries returns the recorded operations, oldest first
+func (j *Journal) Entries() ([]*JournalEntry, error) {
+	dirs, err := os.ReadDir(j.root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %v", err)
+	}
+	var entries []*JournalEntry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		e, err := loadJournalEntry(filepath.Join(j.root, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].ID < entries[b].ID })
+	return entries, nil
+}
+
+// loadJournalEntry reads an entry directory, merging repeated records of a
+// path into its first one with the latest action
+func loadJournalEntry(dir string) (*JournalEntry, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, "entry.json"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid journal entry '%s': %v", dir, err)
+	}
+	e := &JournalEntry{dir: dir}
+	if err := json.Unmarshal(raw, e); err != nil {
+		return nil, fmt.Errorf("invalid journal entry '%s': %v", dir, err)
+	}
+
+	in, err := os.Open(filepath.Join(dir, "files.jsonl"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid journal entry '%s': %v", dir, err)
+	}
+	defer in.Close()
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		var f JournalFile
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			return nil, fmt.Errorf("invalid journal entry '%s': %v", dir, err)
+		}
+		i, ok := index[f.Path]
+		if !ok {
+			index[f.Path] = len(e.Files)
+			e.Files = append(e.Files, f)
+			continue
+		}
+		// A file created by the operation stays created whatever happened next
+		if e.Files[i].Existed {
+			e.Files[i].Action = f.Action
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid journal entry '%s': %v", dir, err)
+	}
+	return e, nil
+}
+
+// Undo restores every file of the entry to its state before the operation,
+// removes the files and empty directories it created and drops the entry
+func (j *Journal) Undo(e *JournalEntry) error {
+	for i := len(e.Files) - 1; i >= 0; i-- {
+		f := e.Files[i]
+		switch {
+		case f.Action == ActionMkdir:
+			// Directories still holding other files are kept
+			os.Remove(f.Path)
+		case f.Existed:
+			data, err := os.ReadFile(filepath.Join(e.dir, f.Prior))
+			if err != nil {
+				return fmt.Errorf("failed to read prior content of '%s': %v", f.Path, err)
+			}
+			if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(f.Path, data, 0644); err != nil {
+				return fmt.Errorf("failed to restore '%s': %v", f.Path, err)
+			}
+		default:
+			if err := os.Remove(f.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to remove '%s': %v", f.Path, err)
+			}
+		}
+	}
+	return os.RemoveAll(e.dir)
+}
@@ -0,0 +1,201 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournalUndo(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, j *Journal, dir string)
+	}{
+		{"modify", func(t *testing.T, j *Journal, dir string) {
+			write(t, j, filepath.Join(dir, "a.syx"), "changed")
+		}},
+		{"modify twice", func(t *testing.T, j *Journal, dir string) {
+			write(t, j, filepath.Join(dir, "a.syx"), "first")
+			write(t, j, filepath.Join(dir, "a.syx"), "second")
+		}},
+		{"create", func(t *testing.T, j *Journal, dir string) {
+			write(t, j, filepath.Join(dir, "new.syx"), "new")
+		}},
+		{"delete", func(t *testing.T, j *Journal, dir string) {
+			remove(t, j, filepath.Join(dir, "b.txt"))
+		}},
+		{"create then delete", func(t *testing.T, j *Journal, dir string) {
+			path := filepath.Join(dir, "tmp.syx")
+			write(t, j, path, "tmp")
+			remove(t, j, path)
+		}},
+		{"mkdir", func(t *testing.T, j *Journal, dir string) {
+			sub := filepath.Join(dir, "presets", "Oil")
+			for _, d := range []string{filepath.Dir(sub), sub} {
+				if err := j.Record(d, ActionMkdir, func() error { return os.Mkdir(d, 0755) }); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write(t, j, filepath.Join(sub, "c.syx"), "c")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			before := map[string]string{"a.syx": "original", "b.txt": "notes"}
+			for name, content := range before {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tt.run(t, OpenJournal(dir, "test"), dir)
+
+			j := OpenJournal(dir, "--undo")
+			entries, err := j.Entries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Command != "test" {
+				t.Fatalf("got %d entries, want the one of the operation", len(entries))
+			}
+			if err := j.Undo(entries[0]); err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range files {
+				if f.Name() == JournalDir {
+					continue
+				}
+				want, ok := before[f.Name()]
+				if !ok {
+					t.Errorf("%s was not removed", f.Name())
+					continue
+				}
+				got, _ := os.ReadFile(filepath.Join(dir, f.Name()))
+				if !bytes.Equal(got, []byte(want)) {
+					t.Errorf("%s holds %q, want %q", f.Name(), got, want)
+				}
+				delete(before, f.Name())
+			}
+			for name := range before {
+				t.Errorf("%s was not restored", name)
+			}
+			if entries, _ := j.Entries(); len(entries) != 0 {
+				t.Errorf("%d entries left after undo", len(entries))
+			}
+		})
+	}
+}
+
+func TestJournalUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	if entries, err := OpenJournal(dir, "test").Entries(); err != nil || len(entries) != 0 {
+		t.Fatalf("got %d entries (%v), want none", len(entries), err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, JournalDir)); err == nil {
+		t.Error("journal directory created without any change")
+	}
+}
+
+func TestLibraryRoot(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+	sub := filepath.Join(lib, "presets", "Oil")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, from, want string
+	}{
+		{"presets directory", sub, lib},
+		{"library itself", lib, lib},
+		{"outside", dir, dir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LibraryRoot(tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// In nested libraries the nearest one wins, whichever marker it has
+	if err := os.Mkdir(filepath.Join(dir, JournalDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	inner := filepath.Join(sub, "mine")
+	if err := os.MkdirAll(filepath.Join(inner, JournalDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := []struct {
+		name, from, want string
+	}{
+		{"presets directory inside a journaled library", sub, lib},
+		{"journal inside a presets library", inner, inner},
+		{"outer journal", dir, dir},
+	}
+	for _, tt := range nested {
+		if got, _ := LibraryRoot(tt.from); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJournalFailedChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.syx")
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	j := OpenJournal(dir, "test")
+	failed := errors.New("disk full")
+	if err := j.Record(path, ActionModify, func() error { return failed }); err != failed {
+		t.Fatalf("Record() = %v, want the change error", err)
+	}
+	if entries, err := j.Entries(); err != nil || len(entries) != 0 {
+		t.Fatalf("Entries() = %v, %v; want no entry for a failed change", entries, err)
+	}
+
+	// A later successful change still saves the original content
+	write(t, j, path, "changed")
+	entries, err := j.Entries()
+	if err != nil || len(entries) != 1 || len(entries[0].Files) != 1 {
+		t.Fatalf("Entries() = %v, %v; want one entry with one file", entries, err)
+	}
+	if err := j.Undo(entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "original" {
+		t.Errorf("after undo %s = %q, want %q", path, data, "original")
+	}
+}
+
+// write records path in the journal and writes content to it
+func write(t *testing.T, j *Journal, path, content string) {
+	t.Helper()
+	err := j.Record(path, ActionModify, func() error {
+		return os.WriteFile(path, []byte(content), 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// remove records path in the journal and deletes it
+func remove(t *testing.T, j *Journal, path string) {
+	t.Helper()
+	if err := j.Record(path, ActionDelete, func() error { return os.Remove(path) }); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ParamValue is a single named parameter value
+type ParamValue struct {
+	Name  string
+	Value int
+}
+
+// ParamValues is an ordered list of parameter values encoded as a JSON
+// object, so exported documents keep the patch layout order
+type ParamValues []ParamValue
+
+// MarshalJSON encodes the values as an object in list order
+func (pv ParamValues) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range pv {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		fmt.Fprintf(&buf, ":%d", v.Value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes an object keeping the order of its keys
+func (pv *ParamValues) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("params must be a JSON object")
+	}
+	seen := make(map[string]struct{})
+	var out ParamValues
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+		if _, dup := seen[name]; dup {
+			return fmt.Errorf("duplicate parameter '%s'", name)
+		}
+		seen[name] = struct{}{}
+		var val int
+		if err := dec.Decode(&val); err != nil {
+			return fmt.Errorf("parameter '%s': %v", name, err)
+		}
+		out = append(out, ParamValue{Name: name, Value: val})
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	*pv = out
+	return nil
+}
+
+// Document is the JSON representation of a patch
+type Document struct {
+	Name     string      `json:"name"`
+	Category string      `json:"category"`
+	Reserved []int       `json:"reserved,omitempty"`
+	Params   ParamValues `json:"params"`
+}
+
+// BundleDocument is the JSON representation of a bundle
+type BundleDocument struct {
+	Patches []Document `json:"patches"`
+}
+
+// Document returns the JSON representation of the patch using the offsets
+// in spec. Reserved bytes 17..19 are only included when non-zero.
+func (p *Patch) Document(spec Spec) Document {
+	doc := Document{Name: p.Name(), Category: p.Category()}
+	if reserved := p.data[CategoryOffset+1 : FirstParamOffset]; !bytes.Equal(reserved, []byte{0, 0, 0}) {
+		for _, b := range reserved {
+			doc.Reserved = append(doc.Reserved, int(b))
+		}
+	}
+	for _, name := range spec.Names() {
+		if v, err := p.Param(spec, name); err == nil {
+			doc.Params = append(doc.Params, ParamValue{Name: name, Value: v})
+		}
+	}
+	return doc
+}
+
+// FromDocument builds a patch from its JSON representation. Bytes not
+// covered by spec are inherited from base.
+func FromDocument(base *Patch, doc Document, spec Spec) (*Patch, error) {
+	code, ok := CategoryCode(doc.Category)
+	if !ok {
+		return nil, fmt.Errorf("unknown category '%s'", doc.Category)
+	}
+	if len(doc.Name) > NameLength {
+		return nil, fmt.Errorf("name '%s' is longer than %d characters", doc.Name, NameLength)
+	}
+	cfg := make(map[string]int, len(doc.Params))
+	for _, v := range doc.Params {
+		cfg[v.Name] = v.Value
+	}
+	p, err := Build(base, doc.Name, code, spec, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Reserved != nil {
+		if len(doc.Reserved) != FirstParamOffset-CategoryOffset-1 {
+			return nil, fmt.Errorf("reserved must hold %d bytes", FirstParamOffset-CategoryOffset-1)
+		}
+		for i, b := range doc.Reserved {
+			if b < 0 || b > 0x7F {
+				return nil, fmt.Errorf("reserved byte %d is not a 7-bit value", b)
+			}
+			p.data[CategoryOffset+1+i] = byte(b)
+		}
+	}
+	return p, nil
+}
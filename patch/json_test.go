@@ -0,0 +1,79 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	spec := Spec{
+		"FLT_Cutoff":    {Min: 0, Max: 127, SysexOffset: 36, SysexLength: 1, Scale: "linear"},
+		"FLT_Resonance": {Min: 0, Max: 127, SysexOffset: 37, SysexLength: 1, Scale: "linear"},
+		"OSC1_Algo":     {Min: 0, Max: 30, SysexOffset: 20, SysexLength: 1, Scale: "enum"},
+	}
+	base, err := New(rawPatch())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		category string
+		cfg      map[string]int
+		reserved []byte
+	}{
+		{"defaults", "Bass", nil, nil},
+		{"values", "Lead", map[string]int{"FLT_Cutoff": 100, "FLT_Resonance": 12, "OSC1_Algo": 7}, nil},
+		{"reserved bytes", "Drone", map[string]int{"FLT_Cutoff": 1}, []byte{1, 0, 0x7F}},
+		{"short name", "User3", map[string]int{"OSC1_Algo": 30}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := CategoryCode(tt.category)
+			p, err := Build(base, "Test", code, spec, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			copy(p.data[CategoryOffset+1:], tt.reserved)
+
+			raw, err := json.Marshal(p.Document(spec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc Document
+			if err := json.Unmarshal(raw, &doc); err != nil {
+				t.Fatal(err)
+			}
+			got, err := FromDocument(base, doc, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), p.Bytes()) {
+				t.Errorf("round trip through %s changed the patch", raw)
+			}
+			if doc.Params[0].Name != "OSC1_Algo" {
+				t.Errorf("params start with %s, want layout order", doc.Params[0].Name)
+			}
+		})
+	}
+}
+
+func TestFromDocumentErrors(t *testing.T) {
+	base, _ := New(rawPatch())
+	tests := []struct {
+		name string
+		doc  Document
+	}{
+		{"unknown category", Document{Name: "x", Category: "Piano"}},
+		{"long name", Document{Name: "TooLongName", Category: "Bass"}},
+		{"bad reserved size", Document{Name: "x", Category: "Bass", Reserved: []int{1}}},
+		{"bad reserved byte", Document{Name: "x", Category: "Bass", Reserved: []int{0, 200, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := FromDocument(base, tt.doc, Spec{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
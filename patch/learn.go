@@ -0,0 +1,44 @@
+package patch
+
+// LearnSpec builds a spec covering the space occupied by patches: for every
+// parameter of ref, min and max are the observed extremes and default is the
+// most frequent value. Offsets, sections, scales and units are taken from ref;
+// its sampling distribution is not, as it was tuned for ref's ranges.
+func LearnSpec(patches []*Patch, ref Spec) Spec {
+	learned := make(Spec, len(ref))
+	if len(patches) == 0 {
+		return learned
+	}
+	for _, name := range ref.Names() {
+		counts := make(map[int]int)
+		for _, p := range patches {
+			v, err := p.Param(ref, name)
+			if err != nil {
+				break
+			}
+			counts[v]++
+		}
+		if len(counts) == 0 {
+			continue
+		}
+
+		info := ref[name]
+		info.PreferDefault, info.StdDev, info.Weights = 0, 0, nil
+		info.Min, info.Max = 0x7F, 0
+		mode := -1
+		for v, c := range counts {
+			if v < info.Min {
+				info.Min = v
+			}
+			if v > info.Max {
+				info.Max = v
+			}
+			if mode < 0 || c > counts[mode] || (c == counts[mode] && v < mode) {
+				mode = v
+			}
+		}
+		info.Default = mode
+		learned[name] = info
+	}
+	return learned
+}
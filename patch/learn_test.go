@@ -0,0 +1,51 @@
+package patch
+
+import "testing"
+
+func TestLearnSpec(t *testing.T) {
+	ref := Spec{
+		"Cutoff": {Min: 0, Max: 100, Default: 50, SysexOffset: 20, SysexLength: 1, Scale: "linear", Section: "Filter", StdDev: 10},
+		"Algo": {Min: 0, Max: 9, SysexOffset: 21, SysexLength: 1, Scale: "enum", Section: "Oscillator 1",
+			PreferDefault: 0.5, Weights: []WeightedValue{{Value: 0, Weight: 1}, {Value: 9, Weight: 5}}},
+	}
+	var patches []*Patch
+	for _, v := range [][2]byte{{30, 2}, {70, 4}, {40, 4}, {70, 2}, {70, 4}} {
+		patches = append(patches, mutateParent(t, v[0], v[1]))
+	}
+
+	learned := LearnSpec(patches, ref)
+	tests := []struct {
+		name            string
+		min, max, deflt int
+		section         string
+	}{
+		{"Cutoff", 30, 70, 70, "Filter"},
+		{"Algo", 2, 4, 4, "Oscillator 1"},
+	}
+	for _, tt := range tests {
+		info, ok := learned[tt.name]
+		if !ok {
+			t.Fatalf("%s missing from learned spec", tt.name)
+		}
+		if info.Min != tt.min || info.Max != tt.max || info.Default != tt.deflt {
+			t.Errorf("%s = %d..%d default %d, want %d..%d default %d", tt.name, info.Min, info.Max, info.Default, tt.min, tt.max, tt.deflt)
+		}
+		if info.Section != tt.section || info.SysexOffset != ref[tt.name].SysexOffset || info.Scale != ref[tt.name].Scale {
+			t.Errorf("%s layout = %+v, want taken from ref", tt.name, info)
+		}
+		if info.PreferDefault != 0 || info.StdDev != 0 || info.Weights != nil {
+			t.Errorf("%s kept the reference distribution: %+v", tt.name, info)
+		}
+	}
+
+	if got := LearnSpec(nil, ref); len(got) != 0 {
+		t.Errorf("LearnSpec(nil) = %v, want empty", got)
+	}
+}
+
+func TestLearnSpecModeTie(t *testing.T) {
+	patches := []*Patch{mutateParent(t, 60, 0), mutateParent(t, 20, 0)}
+	if got := LearnSpec(patches, mutateSpec)["Cutoff"].Default; got != 20 {
+		t.Errorf("Default = %d, want the lowest of tied values 20", got)
+	}
+}
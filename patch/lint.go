@@ -0,0 +1,199 @@
+package patch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SpecProblem describes a single problem found in a spec file
+type SpecProblem struct {
+	Param   string // parameter name, empty when the problem concerns the whole spec
+	Message string
+	Warning bool // the spec still works, e.g. ranges are clamped to the schema
+}
+
+func (p SpecProblem) Error() string {
+	if p.Param == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("%s: %s", p.Param, p.Message)
+}
+
+// LintSpec checks a single spec against the schema: unknown names, inverted
+// or out-of-schema ranges, defaults outside the range, invalid or colliding
+// offsets, unsupported lengths and scales, invalid distributions, and schema
+// parameters it misses. Out-of-schema ranges, misplaced defaults or weighted
+// values and missing sections are warnings.
+func LintSpec(spec Spec, schema Schema) []SpecProblem {
+	var problems []SpecProblem
+	byOffset := make(map[int][]string)
+	for _, name := range spec.Names() {
+		info := spec[name]
+		add := func(format string, args ...interface{}) {
+			problems = append(problems, SpecProblem{Param: name, Message: fmt.Sprintf(format, args...)})
+		}
+		warn := func(format string, args ...interface{}) {
+			problems = append(problems, SpecProblem{Param: name, Message: fmt.Sprintf(format, args...), Warning: true})
+		}
+
+		r, known := schema[name]
+		if !known {
+			add("unknown parameter, not in schema")
+		}
+		if info.Min > info.Max {
+			add("min %d is greater than max %d", info.Min, info.Max)
+		}
+		if known && (info.Max < r.Min || info.Min > r.Max) {
+			add("range %d..%d does not overlap schema range %d..%d, no valid value can be generated", info.Min, info.Max, r.Min, r.Max)
+		} else if known && (info.Min < r.Min || info.Max > r.Max) {
+			warn("range %d..%d outside schema range %d..%d", info.Min, info.Max, r.Min, r.Max)
+		}
+		if info.Min <= info.Max && (info.Default < info.Min || info.Default > info.Max) {
+			warn("default %d outside range %d..%d", info.Default, info.Min, info.Max)
+		}
+		if info.SysexOffset < FirstParamOffset || info.SysexOffset > LastParamOffset {
+			add("sysex_offset %d outside %d..%d", info.SysexOffset, FirstParamOffset, LastParamOffset)
+		} else {
+			byOffset[info.SysexOffset] = append(byOffset[info.SysexOffset], name)
+		}
+		if info.SysexLength != 1 {
+			add("sysex_length %d, only single-byte parameters are supported", info.SysexLength)
+		}
+		if info.Scale != "linear" && info.Scale != "enum" {
+			add("scale '%s', expected linear or enum", info.Scale)
+		}
+		if info.Section == "" {
+			warn("no section")
+		}
+		if info.PreferDefault < 0 || info.PreferDefault > 1 {
+			add("prefer_default %g outside 0..1", info.PreferDefault)
+		}
+		if info.StdDev < 0 {
+			add("negative stddev %g", info.StdDev)
+		}
+		for _, w := range info.Weights {
+			if w.Weight < 0 {
+				add("negative weight %g for value %d", w.Weight, w.Value)
+			}
+			if w.Value < info.Min || w.Value > info.Max {
+				warn("weighted value %d outside range %d..%d, never drawn", w.Value, info.Min, info.Max)
+			}
+		}
+	}
+
+	offsets := make([]int, 0, len(byOffset))
+	for off, names := range byOffset {
+		if len(names) > 1 {
+			offsets = append(offsets, off)
+		}
+	}
+	sort.Ints(offsets)
+	for _, off := range offsets {
+		problems = append(problems, SpecProblem{
+			Message: fmt.Sprintf("sysex_offset %d used by several parameters: %s", off, strings.Join(byOffset[off], ", ")),
+		})
+	}
+
+	var missing []string
+	for name := range schema {
+		if _, ok := spec[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		problems = append(problems, SpecProblem{
+			Message: fmt.Sprintf("%d schema parameters missing: %s", len(missing), strings.Join(missing, ", ")),
+		})
+	}
+	return problems
+}
+
+// LintSpecSet checks that parameters shared by several specs agree on their
+// offset, section, scale and unit. Each spec deviating from the most common
+// value gets a problem, keyed by the same name as in specs. Only differing
+// offsets are errors, other differences are warnings.
+func LintSpecSet(specs map[string]Spec) map[string][]SpecProblem {
+	files := make([]string, 0, len(specs))
+	for file := range specs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	params := make(map[string]bool)
+	for _, spec := range specs {
+		for name := range spec {
+			params[name] = true
+		}
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := []struct {
+		label string
+		value func(ParamInfo) string
+	}{
+		{"sysex_offset", func(i ParamInfo) string { return fmt.Sprint(i.SysexOffset) }},
+		{"section", func(i ParamInfo) string { return i.Section }},
+		{"scale", func(i ParamInfo) string { return i.Scale }},
+		{"unit", func(i ParamInfo) string { return i.Unit }},
+	}
+
+	problems := make(map[string][]SpecProblem)
+	for _, name := range names {
+		for _, field := range fields {
+			counts := make(map[string]int)
+			for _, file := range files {
+				if info, ok := specs[file][name]; ok {
+					counts[field.value(info)]++
+				}
+			}
+			if len(counts) < 2 {
+				continue
+			}
+			common := mostCommon(counts)
+			for _, file := range files {
+				info, ok := specs[file][name]
+				if !ok || field.value(info) == common {
+					continue
+				}
+				problems[file] = append(problems[file], SpecProblem{
+					Param:   name,
+					Message: fmt.Sprintf("%s '%s' differs from '%s' used by other specs", field.label, field.value(info), common),
+					Warning: field.label != "sysex_offset",
+				})
+			}
+		}
+	}
+	return problems
+}
+
+// mostCommon returns the value with the highest count, the smallest on ties
+func mostCommon(counts map[string]int) string {
+	best, bestCount := "", -1
+	for v, c := range counts {
+		if c > bestCount || (c == bestCount && v < best) {
+			best, bestCount = v, c
+		}
+	}
+	return best
+}
+
+// LintRules checks that the rules of a spec only refer to its parameters
+func LintRules(rules []Rule, spec Spec) []SpecProblem {
+	var problems []SpecProblem
+	for _, r := range rules {
+		for _, name := range r.Params() {
+			if _, ok := spec[name]; !ok {
+				problems = append(problems, SpecProblem{
+					Message: fmt.Sprintf("rule '%s' uses unknown parameter '%s'", r.Name, name),
+				})
+			}
+		}
+	}
+	return problems
+}
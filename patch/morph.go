@@ -0,0 +1,28 @@
+package patch
+
+import "math"
+
+// Interpolate returns a patch between a (t=0) and b (t=1). Linear-scale
+// parameters are interpolated; every other parameter and all bytes outside
+// spec (name, category, reserved bytes) switch from a to b once t reaches
+// crossover.
+func Interpolate(a, b *Patch, spec Spec, t, crossover float64) *Patch {
+	out := a.Clone()
+	if t >= crossover {
+		out = b.Clone()
+	}
+
+	for _, name := range spec.Names() {
+		if spec[name].Scale != "linear" {
+			continue
+		}
+		va, err := a.Param(spec, name)
+		if err != nil {
+			continue
+		}
+		vb, _ := b.Param(spec, name)
+		v := int(math.Round(float64(va) + (float64(vb)-float64(va))*t))
+		out.data[spec[name].SysexOffset] = byte(v)
+	}
+	return out
+}
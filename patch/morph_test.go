@@ -0,0 +1,41 @@
+package patch
+
+import "testing"
+
+func TestInterpolate(t *testing.T) {
+	a, _ := New(rawPatch())
+	b, _ := New(rawPatch())
+	a.SetName("First")
+	b.SetName("Second")
+	a.SetCategory(0)
+	b.SetCategory(1)
+	a.data[20], a.data[21] = 0, 3 // Cutoff, Algo
+	b.data[20], b.data[21] = 100, 7
+	a.data[40], b.data[40] = 11, 22 // outside the spec
+
+	tests := []struct {
+		t, crossover float64
+		cutoff, algo int
+		name         string
+		outside      byte
+	}{
+		{0, 0.5, 0, 3, "First", 11},
+		{0.25, 0.5, 25, 3, "First", 11},
+		{0.5, 0.5, 50, 7, "Second", 22},
+		{0.5, 0.75, 50, 3, "First", 11},
+		{0.333, 0.5, 33, 3, "First", 11},
+		{1, 0.5, 100, 7, "Second", 22},
+	}
+	for _, tt := range tests {
+		p := Interpolate(a, b, mutateSpec, tt.t, tt.crossover)
+		cutoff, _ := p.Param(mutateSpec, "Cutoff")
+		algo, _ := p.Param(mutateSpec, "Algo")
+		if cutoff != tt.cutoff || algo != tt.algo || p.Name() != tt.name || p.data[40] != tt.outside {
+			t.Errorf("t=%g crossover=%g: got Cutoff %d, Algo %d, name %s, byte %d; want %d, %d, %s, %d",
+				tt.t, tt.crossover, cutoff, algo, p.Name(), p.data[40], tt.cutoff, tt.algo, tt.name, tt.outside)
+		}
+	}
+	if a.data[20] != 0 || b.data[20] != 100 {
+		t.Error("Interpolate changed a parent")
+	}
+}
@@ -0,0 +1,104 @@
+package patch
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Mutate returns a copy of p whose parameters listed in allowed moved within
+// their allowed values: linear parameters by at most amount of their range,
+// other parameters switch value with probability amount. Parameters are
+// visited in spec order and bytes outside spec are kept.
+func Mutate(p *Patch, spec Spec, allowed map[string][]int, amount float64, rng *rand.Rand) *Patch {
+	out := p.Clone()
+	for _, name := range spec.Names() {
+		vals, ok := allowed[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		off, err := spec.Offset(name)
+		if err != nil {
+			continue
+		}
+		current := int(p.data[off])
+		lo, hi := mutationRange(vals, current)
+		out.data[off] = byte(mutateValue(spec[name], current, lo, hi, amount, rng))
+	}
+	return out
+}
+
+// mutationRange returns the bounds a parameter may move within: its allowed
+// range, widened to include the current value so a preset made outside the
+// spec is not forced back into it. Callers clamp the current value to the
+// schema first, so the bounds never leave the schema range.
+func mutationRange(vals []int, current int) (int, int) {
+	lo, hi := vals[0], vals[len(vals)-1]
+	if current < lo {
+		lo = current
+	}
+	if current > hi {
+		hi = current
+	}
+	return lo, hi
+}
+
+// mutateValue perturbs a single parameter value within lo..hi
+func mutateValue(info ParamInfo, current, lo, hi int, amount float64, rng *rand.Rand) int {
+	if lo == hi {
+		return lo
+	}
+	if info.Scale == "enum" {
+		// Enum values have no notion of distance, switch to another value with
+		// probability amount
+		if rng.Float64() < amount {
+			v := lo + rng.Intn(hi-lo)
+			if v >= current {
+				v++
+			}
+			return v
+		}
+		return current
+	}
+
+	maxStep := int(math.Round(amount * float64(hi-lo)))
+	if maxStep < 1 {
+		maxStep = 1
+	}
+	v := current + rng.Intn(2*maxStep+1) - maxStep
+	if v < lo {
+		v = lo
+	}
+	if v > hi {
+		v = hi
+	}
+	return v
+}
+
+// Variations returns count distinct mutations of parent (see Mutate) that
+// pass checker and differ from parent, drawing at most maxDraws candidates.
+// The number of rejected candidates per checker reason and "duplicate" is
+// returned even when fewer than count variations could be created.
+func Variations(parent *Patch, count, maxDraws int, spec Spec, allowed map[string][]int, checker *Checker, amount float64, rng *rand.Rand) ([]*Patch, map[string]int, error) {
+	seen := map[uint64]struct{}{parent.ParamHash(): {}}
+	patches := make([]*Patch, 0, count)
+	rejected := make(map[string]int)
+	for draws := 0; len(patches) < count; draws++ {
+		if draws >= maxDraws {
+			return patches, rejected, fmt.Errorf("could only create %d distinct variations", len(patches))
+		}
+		child := Mutate(parent, spec, allowed, amount, rng)
+		if reason := checker.Check(child); reason != "" {
+			rejected[reason]++
+			continue
+		}
+		key := child.ParamHash()
+		if _, ex := seen[key]; ex {
+			rejected["duplicate"]++
+			continue
+		}
+		seen[key] = struct{}{}
+		patches = append(patches, child)
+	}
+	return patches, rejected, nil
+}
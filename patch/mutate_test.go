@@ -0,0 +1,120 @@
+package patch
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// mutateSpec is a small spec with a linear and an enum parameter
+var mutateSpec = Spec{
+	"Cutoff": {Min: 0, Max: 100, SysexOffset: 20, SysexLength: 1, Scale: "linear"},
+	"Algo":   {Min: 0, Max: 9, SysexOffset: 21, SysexLength: 1, Scale: "enum"},
+}
+
+func mutateParent(t *testing.T, cutoff, algo byte) *Patch {
+	t.Helper()
+	p, err := New(rawPatch())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetName("Parent")
+	p.data[20], p.data[21], p.data[40] = cutoff, algo, 99
+	return p
+}
+
+func valueRange(lo, hi int) []int {
+	vals := make([]int, 0, hi-lo+1)
+	for v := lo; v <= hi; v++ {
+		vals = append(vals, v)
+	}
+	return vals
+}
+
+func TestMutate(t *testing.T) {
+	tests := []struct {
+		name         string
+		cutoff, algo byte
+		allowed      map[string][]int
+		amount       float64
+		cutoffLo     int
+		cutoffHi     int
+		algoChanges  bool
+	}{
+		{"small step", 50, 3, map[string][]int{"Cutoff": valueRange(0, 100), "Algo": valueRange(0, 9)}, 0.1, 40, 60, false},
+		{"enum always switches", 50, 3, map[string][]int{"Cutoff": valueRange(0, 100), "Algo": valueRange(0, 9)}, 1, 0, 100, true},
+		{"clamped to allowed", 2, 3, map[string][]int{"Cutoff": valueRange(0, 10), "Algo": valueRange(3, 3)}, 0.5, 0, 10, false},
+		{"widened to the parent value", 90, 3, map[string][]int{"Cutoff": valueRange(0, 10)}, 0.1, 81, 90, false},
+		{"locked", 50, 3, map[string][]int{"Algo": valueRange(0, 9)}, 0, 50, 50, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := mutateParent(t, tt.cutoff, tt.algo)
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 200; i++ {
+				child := Mutate(parent, mutateSpec, tt.allowed, tt.amount, rng)
+				cutoff, _ := child.Param(mutateSpec, "Cutoff")
+				algo, _ := child.Param(mutateSpec, "Algo")
+				if cutoff < tt.cutoffLo || cutoff > tt.cutoffHi {
+					t.Fatalf("Cutoff %d outside %d..%d", cutoff, tt.cutoffLo, tt.cutoffHi)
+				}
+				if tt.algoChanges && algo == int(tt.algo) {
+					t.Fatalf("Algo kept %d with amount 1", algo)
+				}
+				if vals, ok := tt.allowed["Algo"]; ok && algo != int(tt.algo) && (algo < vals[0] || algo > vals[len(vals)-1]) {
+					t.Fatalf("Algo %d outside allowed values", algo)
+				}
+				if child.Name() != "Parent" || child.data[40] != 99 || !bytes.Equal(child.data[:8], Header) {
+					t.Fatal("bytes outside the spec changed")
+				}
+			}
+			if parent.data[20] != tt.cutoff || parent.data[21] != tt.algo {
+				t.Error("Mutate changed the parent")
+			}
+		})
+	}
+}
+
+func TestVariations(t *testing.T) {
+	parent := mutateParent(t, 50, 3)
+	allowed := map[string][]int{"Cutoff": valueRange(0, 100), "Algo": valueRange(0, 9)}
+	checker, err := NewChecker(mutateSpec, Schema{"Cutoff": {Min: 0, Max: 55}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patches, rejected, err := Variations(parent, 10, 10000, mutateSpec, allowed, checker, 0.2, rand.New(rand.NewSource(7)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 10 {
+		t.Fatalf("got %d variations, want 10", len(patches))
+	}
+	seen := map[uint64]bool{parent.ParamHash(): true}
+	for _, p := range patches {
+		if seen[p.ParamHash()] {
+			t.Error("variation repeats the parent or another variation")
+		}
+		seen[p.ParamHash()] = true
+		if reason := checker.Check(p); reason != "" {
+			t.Errorf("variation fails the checker: %s", reason)
+		}
+	}
+	if rejected["schema"] == 0 {
+		t.Error("no candidate above the schema range was rejected")
+	}
+
+	again, _, _ := Variations(parent, 10, 10000, mutateSpec, allowed, checker, 0.2, rand.New(rand.NewSource(7)))
+	for i := range patches {
+		if !bytes.Equal(patches[i].Bytes(), again[i].Bytes()) {
+			t.Fatal("the same seed gave different variations")
+		}
+	}
+
+	// Only two other values exist for Cutoff when everything else is locked
+	few := map[string][]int{"Cutoff": valueRange(49, 51)}
+	patches, rejected, err = Variations(parent, 3, 1000, mutateSpec, few, checker, 0.1, rand.New(rand.NewSource(7)))
+	if err == nil || len(patches) != 2 || rejected["duplicate"] == 0 {
+		t.Errorf("got %d variations and error %v, want 2 and an error", len(patches), err)
+	}
+}
@@ -0,0 +1,171 @@
+// Package patch reads, edits and writes Micromonsta 2 SysEx patches and
+// bundles of patches.
+//
+// A patch is a fixed 176-byte SysEx message: an 8-byte header, an 8-character
+// name, a category byte, three reserved bytes, the synth parameters at offsets
+// 20..174 and a terminating F7. A bundle is a plain concatenation of patches.
+package patch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Size is the length in bytes of a single patch
+const Size = 176
+
+// Layout of a patch
+const (
+	NameOffset       = 8
+	NameLength       = 8
+	CategoryOffset   = 16
+	FirstParamOffset = 20
+	LastParamOffset  = Size - 2
+)
+
+// EndOfExclusive terminates every patch
+const EndOfExclusive = 0xF7
+
+// Header is the SysEx header written at the start of every patch
+var Header = []byte{0xF0, 0x00, 0x21, 0x22, 0x4D, 0x02, 0x03, 0x09}
+
+// Patch is a single Micromonsta 2 preset
+type Patch struct {
+	data []byte
+}
+
+// New creates a patch from a copy of raw SysEx bytes
+func New(data []byte) (*Patch, error) {
+	if len(data) != Size {
+		return nil, fmt.Errorf("invalid patch size: %d bytes, expected: %d", len(data), Size)
+	}
+	p := &Patch{data: make([]byte, Size)}
+	copy(p.data, data)
+	return p, nil
+}
+
+// Load reads a file containing exactly one patch
+func Load(path string) (*Patch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sysex file: %v", err)
+	}
+	return parseSingle(path, data)
+}
+
+// LoadFS reads a file of fsys containing exactly one patch
+func LoadFS(fsys fs.FS, name string) (*Patch, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sysex file: %v", err)
+	}
+	return parseSingle(name, data)
+}
+
+// parseSingle checks that the data of a file is exactly one valid patch
+func parseSingle(path string, data []byte) (*Patch, error) {
+	if len(data) != Size {
+		return nil, fmt.Errorf("file '%s' is not a single preset file (size: %d bytes, expected: %d)", path, len(data), Size)
+	}
+	if errs := ValidateStructure(data); len(errs) > 0 {
+		return nil, fmt.Errorf("file '%s': %v", path, ValidationErrors(errs))
+	}
+	return New(data)
+}
+
+// Build creates a patch from base with the given name, category and
+// parameter values, rewriting the header and terminating byte
+func Build(base *Patch, name string, catCode byte, spec Spec, cfg map[string]int) (*Patch, error) {
+	p := base.Clone()
+	copy(p.data, Header)
+	p.SetName(name)
+	p.SetCategory(catCode)
+	p.data[17], p.data[18], p.data[19] = 0, 0, 0
+	for pname, val := range cfg {
+		if err := p.SetParam(spec, pname, val); err != nil {
+			return nil, err
+		}
+	}
+	p.data[Size-1] = EndOfExclusive
+	return p, nil
+}
+
+// Bytes returns the raw SysEx bytes of the patch
+func (p *Patch) Bytes() []byte {
+	out := make([]byte, Size)
+	copy(out, p.data)
+	return out
+}
+
+// Clone returns an independent copy of the patch
+func (p *Patch) Clone() *Patch {
+	c := &Patch{data: make([]byte, Size)}
+	copy(c.data, p.data)
+	return c
+}
+
+// Name returns the preset name without padding
+func (p *Patch) Name() string {
+	return strings.TrimRight(string(p.data[NameOffset:NameOffset+NameLength]), " \x00")
+}
+
+// SetName stores name in the patch, truncated to 8 characters and space padded
+func (p *Patch) SetName(name string) {
+	for i := 0; i < NameLength; i++ {
+		if i < len(name) {
+			p.data[NameOffset+i] = name[i]
+		} else {
+			p.data[NameOffset+i] = 0x20 // space padding
+		}
+	}
+}
+
+// CategoryCode returns the raw category byte
+func (p *Patch) CategoryCode() byte {
+	return p.data[CategoryOffset]
+}
+
+// Category returns the category name, or "Unknown"
+func (p *Patch) Category() string {
+	return CategoryName(p.data[CategoryOffset])
+}
+
+// SetCategory stores the category byte
+func (p *Patch) SetCategory(code byte) {
+	p.data[CategoryOffset] = code
+}
+
+// Param returns the value of the named parameter using the offsets in spec
+func (p *Patch) Param(spec Spec, name string) (int, error) {
+	off, err := spec.Offset(name)
+	if err != nil {
+		return 0, err
+	}
+	return int(p.data[off]), nil
+}
+
+// SetParam stores the value of the named parameter using the offsets in spec
+func (p *Patch) SetParam(spec Spec, name string, val int) error {
+	off, err := spec.Offset(name)
+	if err != nil {
+		return err
+	}
+	if val < 0 || val > 0x7F {
+		return fmt.Errorf("value %d for parameter '%s' is not a 7-bit value", val, name)
+	}
+	p.data[off] = byte(val)
+	return nil
+}
+
+// Params returns the value of every parameter of spec that has a valid offset
+func (p *Patch) Params(spec Spec) map[string]int {
+	vals := make(map[string]int, len(spec))
+	for name := range spec {
+		if v, err := p.Param(spec, name); err == nil {
+			vals[name] = v
+		}
+	}
+	return vals
+}
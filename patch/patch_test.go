@@ -0,0 +1,54 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadFS(t *testing.T) {
+	base := rawPatch()
+	copy(base[8:16], "Base    ")
+	fsys := fstest.MapFS{
+		"Bass.syx":   {Data: base},
+		"short.syx":  {Data: base[:Size-1]},
+		"bundle.syx": {Data: append(rawPatch(), rawPatch()...)},
+	}
+
+	p, err := LoadFS(fsys, "Bass.syx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "Base" {
+		t.Errorf("Name() = %q, want %q", p.Name(), "Base")
+	}
+
+	for _, name := range []string{"short.syx", "bundle.syx", "missing.syx"} {
+		if _, err := LoadFS(fsys, name); err == nil {
+			t.Errorf("LoadFS(%s) succeeded, want error", name)
+		}
+	}
+}
+
+func TestBuildKeepsBase(t *testing.T) {
+	base := mutateParent(t, 10, 3)
+	p, err := Build(base, "Child", 2, mutateSpec, map[string]int{"Cutoff": 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := p.Param(mutateSpec, "Cutoff"); got != 50 {
+		t.Errorf("Cutoff = %d, want 50", got)
+	}
+	if got, _ := p.Param(mutateSpec, "Algo"); got != 3 {
+		t.Errorf("Algo = %d, want 3 from the base", got)
+	}
+	if p.data[40] != 99 {
+		t.Errorf("byte 40 = %d, want 99 from the base", p.data[40])
+	}
+	if strings.TrimSpace(p.Name()) != "Child" || p.CategoryCode() != 2 {
+		t.Errorf("name/category = %q/%d, want Child/2", p.Name(), p.CategoryCode())
+	}
+	if base.Name() != "Parent" {
+		t.Errorf("base was modified: name %q", base.Name())
+	}
+}
@@ -0,0 +1,138 @@
+package patch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RulesKey is the spec file key holding cross-parameter rules
+const RulesKey = "rules"
+
+// comparison operators, two-character ones first so ">=" is not read as ">"
+var ruleOps = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// Rule is a named constraint between parameters, written either as a
+// condition ("MIX1 + MIX2 + MIX3 >= 40") or as an implication
+// ("if FLT_Cutoff < 30 then FLT_EnvAmt > 64"). A condition compares the sum
+// of one or more parameters with an integer.
+type Rule struct {
+	Name string `json:"name"`
+	Rule string `json:"rule"`
+
+	cond, then *condition
+}
+
+type condition struct {
+	params []string
+	op     string
+	value  int
+}
+
+// ParseRule compiles the text of a rule
+func ParseRule(name, text string) (Rule, error) {
+	r := Rule{Name: name, Rule: text}
+	if name == "" {
+		return r, fmt.Errorf("rule '%s' has no name", text)
+	}
+	var err error
+	body := strings.TrimSpace(text)
+	if rest, ok := cutWord(body, "if"); ok {
+		parts := strings.SplitN(rest, " then ", 2)
+		if len(parts) != 2 {
+			return r, fmt.Errorf("rule '%s': 'if' without 'then'", name)
+		}
+		if r.cond, err = parseCondition(parts[0]); err != nil {
+			return r, fmt.Errorf("rule '%s': %v", name, err)
+		}
+		if r.then, err = parseCondition(parts[1]); err != nil {
+			return r, fmt.Errorf("rule '%s': %v", name, err)
+		}
+		return r, nil
+	}
+	if r.cond, err = parseCondition(body); err != nil {
+		return r, fmt.Errorf("rule '%s': %v", name, err)
+	}
+	return r, nil
+}
+
+// cutWord strips a leading keyword followed by a space
+func cutWord(s, word string) (string, bool) {
+	if len(s) > len(word) && strings.EqualFold(s[:len(word)], word) && s[len(word)] == ' ' {
+		return s[len(word)+1:], true
+	}
+	return s, false
+}
+
+func parseCondition(text string) (*condition, error) {
+	for _, op := range ruleOps {
+		i := strings.Index(text, op)
+		if i < 0 {
+			continue
+		}
+		c := &condition{op: op}
+		for _, term := range strings.Split(text[:i], "+") {
+			term = strings.TrimSpace(term)
+			if term == "" || strings.ContainsAny(term, " <>=!") {
+				return nil, fmt.Errorf("invalid parameter '%s' in '%s'", term, strings.TrimSpace(text))
+			}
+			c.params = append(c.params, term)
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(text[i+len(op):]))
+		if err != nil {
+			return nil, fmt.Errorf("expected a number after '%s' in '%s'", op, strings.TrimSpace(text))
+		}
+		c.value = v
+		return c, nil
+	}
+	return nil, fmt.Errorf("no comparison operator in '%s'", strings.TrimSpace(text))
+}
+
+// compare applies a comparison operator of a rule
+func compare(sum int, op string, value int) bool {
+	switch op {
+	case ">=":
+		return sum >= value
+	case "<=":
+		return sum <= value
+	case "==":
+		return sum == value
+	case "!=":
+		return sum != value
+	case ">":
+		return sum > value
+	default:
+		return sum < value
+	}
+}
+
+// Params returns the parameter names the rule refers to
+func (r Rule) Params() []string {
+	var names []string
+	for _, c := range []*condition{r.cond, r.then} {
+		if c != nil {
+			names = append(names, c.params...)
+		}
+	}
+	return names
+}
+
+// MergeRules returns parent followed by the rules of child; a child rule
+// with the same name as a parent rule replaces it in place
+func MergeRules(parent, child []Rule) []Rule {
+	merged := append([]Rule(nil), parent...)
+	for _, r := range child {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == r.Name {
+				merged[i] = r
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
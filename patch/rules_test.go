@@ -0,0 +1,72 @@
+package patch
+
+import "testing"
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		text    string
+		wantErr bool
+	}{
+		{"MIX1 + MIX2 + MIX3 >= 40", false},
+		{"FLT_Cutoff != 0", false},
+		{"if FLT_Cutoff < 30 then FLT_EnvAmt > 64", false},
+		{"IF OSC1_Algo == 3 then OSC1_Shape <= 10", false},
+		{"if FLT_Cutoff < 30", true},
+		{"FLT_Cutoff", true},
+		{"FLT_Cutoff > high", true},
+		{"+ MIX2 > 3", true},
+		{"FLT Cutoff > 3", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			_, err := ParseRule("test", tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := ParseRule("", "A > 1"); err == nil {
+		t.Error("expected an error for a rule without name")
+	}
+}
+
+func TestCheckerRules(t *testing.T) {
+	spec := Spec{
+		"A": {Min: 0, Max: 127, SysexOffset: 20, SysexLength: 1},
+		"B": {Min: 0, Max: 127, SysexOffset: 21, SysexLength: 1},
+	}
+	schema := Schema{"A": {Min: 0, Max: 100}, "B": {Min: 0, Max: 127}}
+	tests := []struct {
+		rule string
+		a, b int
+		want string
+	}{
+		{"if A < 30 then B > 64", 50, 0, ""},
+		{"if A < 30 then B > 64", 10, 100, ""},
+		{"if A < 30 then B > 64", 10, 64, "rule test"},
+		{"if A < 30 then B > 64", 30, 0, ""},
+		{"if A < 30 then B > 64", 120, 100, "schema"},
+		{"A + B >= 40", 20, 20, ""},
+		{"A + B >= 40", 20, 19, "rule test"},
+		{"A == 3", 3, 0, ""},
+		{"A != 3", 3, 0, "rule test"},
+		{"A <= 3", 4, 0, "rule test"},
+		{"A > 3", 4, 0, ""},
+		{"A < 3", 3, 0, "rule test"},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRule("test", tt.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.rule, err)
+		}
+		checker, err := NewChecker(spec, schema, []Rule{rule})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _ := New(rawPatch())
+		p.data[20], p.data[21] = byte(tt.a), byte(tt.b)
+		if got := checker.Check(p); got != tt.want {
+			t.Errorf("%s with A=%d B=%d: got %q, want %q", tt.rule, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
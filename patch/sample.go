@@ -0,0 +1,63 @@
+package patch
+
+import (
+	"math"
+	"math/rand"
+)
+
+// maxNormalDraws bounds how often a normal draw outside the allowed values is
+// retried before it is clamped to the nearest bound
+const maxNormalDraws = 10
+
+// Sample draws a value from vals, the sorted values allowed for the parameter.
+// Without distribution fields the draw is uniform. Otherwise Default is picked
+// with probability PreferDefault, then Weights or a normal distribution around
+// Default with StdDev are used; weights take precedence over StdDev.
+func (info ParamInfo) Sample(vals []int, rng *rand.Rand) int {
+	lo, hi := vals[0], vals[len(vals)-1]
+	if info.PreferDefault > 0 && info.Default >= lo && info.Default <= hi {
+		if rng.Float64() < info.PreferDefault {
+			return info.Default
+		}
+	}
+
+	if len(info.Weights) > 0 {
+		total := 0.0
+		for _, w := range info.Weights {
+			if w.Weight > 0 && w.Value >= lo && w.Value <= hi {
+				total += w.Weight
+			}
+		}
+		if total > 0 {
+			r := rng.Float64() * total
+			last := lo
+			for _, w := range info.Weights {
+				if w.Weight <= 0 || w.Value < lo || w.Value > hi {
+					continue
+				}
+				if r -= w.Weight; r < 0 {
+					return w.Value
+				}
+				last = w.Value
+			}
+			// Only reached through rounding errors on the last weight
+			return last
+		}
+	}
+
+	if info.StdDev > 0 {
+		var v int
+		for i := 0; i < maxNormalDraws; i++ {
+			v = int(math.Round(float64(info.Default) + rng.NormFloat64()*info.StdDev))
+			if v >= lo && v <= hi {
+				return v
+			}
+		}
+		if v < lo {
+			return lo
+		}
+		return hi
+	}
+
+	return vals[rng.Intn(len(vals))]
+}
@@ -0,0 +1,104 @@
+package patch
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// sampleCounts draws n values and counts how often each one was drawn
+func sampleCounts(info ParamInfo, vals []int, n int) map[int]int {
+	rng := rand.New(rand.NewSource(1))
+	counts := make(map[int]int)
+	for i := 0; i < n; i++ {
+		counts[info.Sample(vals, rng)]++
+	}
+	return counts
+}
+
+func TestSampleUniform(t *testing.T) {
+	vals := []int{2, 5, 9}
+	counts := sampleCounts(ParamInfo{}, vals, 3000)
+	if len(counts) != len(vals) {
+		t.Fatalf("drawn values = %v, want exactly %v", counts, vals)
+	}
+	for _, v := range vals {
+		if counts[v] < 800 || counts[v] > 1200 {
+			t.Errorf("value %d drawn %d times out of 3000, want about 1000", v, counts[v])
+		}
+	}
+}
+
+func TestSamplePreferDefault(t *testing.T) {
+	vals := valueRange(0, 10)
+	counts := sampleCounts(ParamInfo{Default: 4, PreferDefault: 1}, vals, 100)
+	if counts[4] != 100 {
+		t.Errorf("counts = %v, want only the default 4", counts)
+	}
+
+	// A default outside the allowed values is never forced
+	counts = sampleCounts(ParamInfo{Default: 20, PreferDefault: 1}, vals, 100)
+	if counts[20] != 0 {
+		t.Errorf("default 20 outside 0..10 drawn %d times", counts[20])
+	}
+
+	counts = sampleCounts(ParamInfo{Default: 4, PreferDefault: 0.5}, vals, 2000)
+	if counts[4] < 1000 || counts[4] > 1200 {
+		t.Errorf("default drawn %d times out of 2000, want about 1090", counts[4])
+	}
+}
+
+func TestSampleWeights(t *testing.T) {
+	vals := valueRange(0, 5)
+	info := ParamInfo{Weights: []WeightedValue{
+		{Value: 1, Weight: 1},
+		{Value: 3, Weight: 3},
+		{Value: 4, Weight: 0},
+		{Value: 8, Weight: 100}, // outside the allowed values
+	}}
+	counts := sampleCounts(info, vals, 4000)
+	if len(counts) != 2 {
+		t.Fatalf("counts = %v, want only values 1 and 3", counts)
+	}
+	if counts[3] < 2800 || counts[3] > 3200 {
+		t.Errorf("value 3 drawn %d times out of 4000, want about 3000", counts[3])
+	}
+
+	// Weights all outside the allowed values fall back to a uniform draw
+	info.Weights = []WeightedValue{{Value: 8, Weight: 1}}
+	if counts := sampleCounts(info, vals, 600); len(counts) != len(vals) {
+		t.Errorf("counts = %v, want every value of %v", counts, vals)
+	}
+}
+
+func TestSampleStdDev(t *testing.T) {
+	vals := valueRange(0, 127)
+	counts := sampleCounts(ParamInfo{Default: 64, StdDev: 5}, vals, 5000)
+	sum, near := 0, 0
+	for v, c := range counts {
+		sum += v * c
+		if math.Abs(float64(v-64)) <= 10 {
+			near += c
+		}
+	}
+	if mean := float64(sum) / 5000; math.Abs(mean-64) > 0.5 {
+		t.Errorf("mean = %.2f, want about 64", mean)
+	}
+	// Two standard deviations hold about 95% of the draws
+	if near < 4650 {
+		t.Errorf("%d of 5000 draws within 64±10, want about 4770", near)
+	}
+
+	// Draws far outside the allowed values are clamped to the nearest bound
+	counts = sampleCounts(ParamInfo{Default: 100, StdDev: 1}, valueRange(0, 10), 100)
+	if counts[10] != 100 {
+		t.Errorf("counts = %v, want every draw clamped to 10", counts)
+	}
+}
+
+func TestSampleWeightsOverStdDev(t *testing.T) {
+	info := ParamInfo{Default: 0, StdDev: 2, Weights: []WeightedValue{{Value: 9, Weight: 1}}}
+	if counts := sampleCounts(info, valueRange(0, 9), 100); counts[9] != 100 {
+		t.Errorf("counts = %v, want weights to take precedence", counts)
+	}
+}
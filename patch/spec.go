@@ -0,0 +1,244 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParamInfo holds metadata for a single synth parameter
+type ParamInfo struct {
+	Min         int    `json:"min"`
+	Max         int    `json:"max"`
+	Default     int    `json:"default"`
+	SysexOffset int    `json:"sysex_offset"`
+	SysexLength int    `json:"sysex_length"`
+	Scale       string `json:"scale"`
+	Unit        string `json:"unit"`
+	Section     string `json:"section"`
+
+	// Optional sampling distribution used by generation instead of a
+	// uniform draw over min..max
+	PreferDefault float64         `json:"prefer_default,omitempty"` // probability of picking Default
+	StdDev        float64         `json:"stddev,omitempty"`         // normal distribution around Default
+	Weights       []WeightedValue `json:"weights,omitempty"`        // relative weights of (enum) values
+}
+
+// WeightedValue gives a value its relative probability in ParamInfo.Weights
+type WeightedValue struct {
+	Value  int     `json:"value"`
+	Weight float64 `json:"weight"`
+}
+
+// Spec maps parameter names to their metadata
+type Spec map[string]ParamInfo
+
+// ExtendsKey is the spec file key naming the spec a file inherits from
+const ExtendsKey = "extends"
+
+// SpecFile is a spec file as stored on disk. It may extend another spec and
+// only override some parameters, or some fields of a parameter, and may add
+// rules constraining several parameters at once.
+type SpecFile struct {
+	Extends string
+	Rules   []Rule
+	Params  map[string]json.RawMessage
+}
+
+// ParseSpecFile parses a spec file without resolving what it extends
+func ParseSpecFile(raw []byte) (*SpecFile, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	f := &SpecFile{Params: fields}
+	if ext, ok := fields[ExtendsKey]; ok {
+		if err := json.Unmarshal(ext, &f.Extends); err != nil {
+			return nil, fmt.Errorf("'%s' must be a string: %v", ExtendsKey, err)
+		}
+		delete(fields, ExtendsKey)
+	}
+	if raw, ok := fields[RulesKey]; ok {
+		var rules []Rule
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return nil, fmt.Errorf("'%s' must be a list of {\"name\", \"rule\"} objects: %v", RulesKey, err)
+		}
+		for _, r := range rules {
+			compiled, err := ParseRule(r.Name, r.Rule)
+			if err != nil {
+				return nil, err
+			}
+			f.Rules = append(f.Rules, compiled)
+		}
+		delete(fields, RulesKey)
+	}
+	return f, nil
+}
+
+// Resolve applies the parameters of the file on top of parent, which may be
+// nil. Fields missing from an overridden parameter keep the parent's value.
+func (f *SpecFile) Resolve(parent Spec) (Spec, error) {
+	spec := make(Spec, len(parent)+len(f.Params))
+	for name, info := range parent {
+		spec[name] = info
+	}
+	for name, raw := range f.Params {
+		info := spec[name]
+		if err := json.Unmarshal(raw, &info); err != nil {
+			return nil, fmt.Errorf("parameter '%s': %v", name, err)
+		}
+		spec[name] = info
+	}
+	return spec, nil
+}
+
+// ParseSpec parses a standalone category spec file. Specs extending another
+// one are loaded with a SpecLoader.
+func ParseSpec(raw []byte) (Spec, error) {
+	f, err := ParseSpecFile(raw)
+	if err != nil {
+		return nil, err
+	}
+	if f.Extends != "" {
+		return nil, fmt.Errorf("spec extends '%s', load it with a SpecLoader", f.Extends)
+	}
+	return f.Resolve(nil)
+}
+
+// Offset returns the sysex offset of the named parameter
+func (s Spec) Offset(name string) (int, error) {
+	info, ok := s[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown parameter '%s'", name)
+	}
+	if info.SysexOffset < FirstParamOffset || info.SysexOffset > LastParamOffset {
+		return 0, fmt.Errorf("parameter '%s' has no valid sysex offset (%d)", name, info.SysexOffset)
+	}
+	return info.SysexOffset, nil
+}
+
+// Section groups parameter names belonging to the same spec section
+type Section struct {
+	Name   string
+	Params []string
+}
+
+// Names returns the parameter names ordered by sysex offset
+func (s Spec) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		oi, oj := s[names[i]].SysexOffset, s[names[j]].SysexOffset
+		if oi != oj {
+			return oi < oj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Sections groups parameters by section, ordering sections and parameters
+// by their sysex offset so they follow the patch layout
+func (s Spec) Sections() []Section {
+	var sections []Section
+	index := make(map[string]int)
+	for _, name := range s.Names() {
+		section := s[name].Section
+		if section == "" {
+			section = "Other"
+		}
+		idx, ok := index[section]
+		if !ok {
+			idx = len(sections)
+			index[section] = idx
+			sections = append(sections, Section{Name: section})
+		}
+		sections[idx].Params = append(sections[idx].Params, name)
+	}
+	return sections
+}
+
+// Select resolves section and parameter names (case-insensitive) to the
+// parameters they cover, ordered by sysex offset. A name that is not a
+// section selects the group of numbered sections it starts, e.g. "Matrix"
+// for Matrix1..Matrix10 or "Oscillator" for "Oscillator 1".."Oscillator 3".
+// Names matching neither a section nor a parameter are returned as unknown.
+func (s Spec) Select(names []string) (selected []string, unknown []string) {
+	sections := s.Sections()
+	picked := make(map[string]bool)
+	for _, name := range names {
+		found := false
+		for _, section := range sections {
+			if strings.EqualFold(section.Name, name) {
+				for _, p := range section.Params {
+					picked[p] = true
+				}
+				found = true
+			}
+		}
+		if !found {
+			for _, section := range sections {
+				if strings.EqualFold(sectionGroup(section.Name), name) {
+					for _, p := range section.Params {
+						picked[p] = true
+					}
+					found = true
+				}
+			}
+		}
+		for p := range s {
+			if strings.EqualFold(p, name) {
+				picked[p] = true
+				found = true
+			}
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	for _, p := range s.Names() {
+		if picked[p] {
+			selected = append(selected, p)
+		}
+	}
+	return selected, unknown
+}
+
+// sectionGroup returns the name of a numbered section without its number,
+// e.g. "Matrix" for Matrix10 and "LFO" for "LFO 1", or "" if it has none
+func sectionGroup(section string) string {
+	group := strings.TrimRight(section, "0123456789")
+	if group == section {
+		return ""
+	}
+	return strings.TrimRight(group, " ")
+}
+
+// MarshalJSON encodes the spec as an object ordered by sysex offset, like the
+// spec files shipped with the tool
+func (s Spec) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range s.Names() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		info, err := json.Marshal(s[name])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(info)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
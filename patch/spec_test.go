@@ -0,0 +1,134 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpecFileResolve(t *testing.T) {
+	parent := Spec{
+		"FLT_Cutoff": {Min: 0, Max: 127, Default: 64, SysexOffset: 36, SysexLength: 1, Scale: "linear", Section: "Filter"},
+		"OSC1_Algo":  {Min: 0, Max: 30, SysexOffset: 20, SysexLength: 1, Scale: "enum", Section: "Oscillator 1"},
+	}
+	tests := []struct {
+		name   string
+		raw    string
+		parent Spec
+		want   Spec
+	}{
+		{
+			name: "standalone",
+			raw:  `{"OSC1_Algo": {"min": 2, "max": 5, "sysex_offset": 20, "sysex_length": 1, "scale": "enum"}}`,
+			want: Spec{"OSC1_Algo": {Min: 2, Max: 5, SysexOffset: 20, SysexLength: 1, Scale: "enum"}},
+		},
+		{
+			name:   "inherit everything",
+			raw:    `{"extends": "default"}`,
+			parent: parent,
+			want:   parent,
+		},
+		{
+			name:   "override fields",
+			raw:    `{"extends": "default", "FLT_Cutoff": {"min": 20, "max": 90}}`,
+			parent: parent,
+			want: Spec{
+				"FLT_Cutoff": {Min: 20, Max: 90, Default: 64, SysexOffset: 36, SysexLength: 1, Scale: "linear", Section: "Filter"},
+				"OSC1_Algo":  parent["OSC1_Algo"],
+			},
+		},
+		{
+			name:   "add parameter",
+			raw:    `{"extends": "default", "FLT_Resonance": {"min": 0, "max": 127, "sysex_offset": 37, "sysex_length": 1}, "rules": [{"name": "r", "rule": "FLT_Cutoff > 10"}]}`,
+			parent: parent,
+			want: Spec{
+				"FLT_Cutoff":    parent["FLT_Cutoff"],
+				"OSC1_Algo":     parent["OSC1_Algo"],
+				"FLT_Resonance": {Min: 0, Max: 127, SysexOffset: 37, SysexLength: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseSpecFile([]byte(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := f.Resolve(tt.parent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if parent["FLT_Cutoff"].Min != 0 || len(parent) != 2 {
+		t.Error("Resolve modified the parent spec")
+	}
+}
+
+func TestSpecFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"syntax", `{"FLT_Cutoff": `},
+		{"extends not a string", `{"extends": 3}`},
+		{"bad rules", `{"rules": {"name": "r"}}`},
+		{"bad rule", `{"rules": [{"name": "r", "rule": "FLT_Cutoff"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSpecFile([]byte(tt.raw)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	f, err := ParseSpecFile([]byte(`{"FLT_Cutoff": {"min": "low"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Resolve(nil); err == nil {
+		t.Error("expected an error for a bad parameter")
+	}
+	if _, err := ParseSpec([]byte(`{"extends": "default"}`)); err == nil {
+		t.Error("expected ParseSpec to reject a spec with extends")
+	}
+}
+
+func TestSpecSelect(t *testing.T) {
+	spec := Spec{
+		"OSC1_Algo":   {SysexOffset: 20, Section: "Oscillator 1"},
+		"OSC2_Algo":   {SysexOffset: 24, Section: "Oscillator 2"},
+		"MIX1":        {SysexOffset: 32, Section: "MIX1"},
+		"MIX_Level":   {SysexOffset: 33, Section: "Mix"},
+		"FLT_Cutoff":  {SysexOffset: 36, Section: "Filter"},
+		"Matrix1_Src": {SysexOffset: 90, Section: "Matrix1"},
+		"Matrix2_Src": {SysexOffset: 93, Section: "Matrix2"},
+		"Matrix10_Am": {SysexOffset: 120, Section: "Matrix10"},
+		"LFO1_Rate":   {SysexOffset: 60, Section: "LFO 1"},
+		"LFO3_Rate":   {SysexOffset: 66, Section: "LFO3"},
+	}
+	tests := []struct {
+		names    []string
+		selected []string
+		unknown  []string
+	}{
+		{[]string{"Filter"}, []string{"FLT_Cutoff"}, nil},
+		{[]string{"filter", "osc1_algo"}, []string{"OSC1_Algo", "FLT_Cutoff"}, nil},
+		{[]string{"Matrix"}, []string{"Matrix1_Src", "Matrix2_Src", "Matrix10_Am"}, nil},
+		{[]string{"Matrix1"}, []string{"Matrix1_Src"}, nil},
+		{[]string{"Oscillator"}, []string{"OSC1_Algo", "OSC2_Algo"}, nil},
+		{[]string{"lfo"}, []string{"LFO1_Rate", "LFO3_Rate"}, nil},
+		{[]string{"Mix"}, []string{"MIX_Level"}, nil}, // a section wins over a group
+		{[]string{"Matr", "Filter"}, []string{"FLT_Cutoff"}, []string{"Matr"}},
+		{[]string{"Envelope"}, nil, []string{"Envelope"}},
+	}
+	for _, tt := range tests {
+		selected, unknown := spec.Select(tt.names)
+		if !reflect.DeepEqual(selected, tt.selected) || !reflect.DeepEqual(unknown, tt.unknown) {
+			t.Errorf("%v: got %v, unknown %v; want %v, unknown %v", tt.names, selected, unknown, tt.selected, tt.unknown)
+		}
+	}
+}
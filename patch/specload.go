@@ -0,0 +1,113 @@
+package patch
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SpecLoader loads the category specs of spec directories, resolving the
+// specs they extend. A directory is an opaque name handed to Dir and Profile.
+type SpecLoader struct {
+	// Dir returns the file system holding the spec files of directory dir
+	Dir func(dir string) (fs.FS, error)
+	// Profile returns the directory of a profile named in "extends" by a
+	// spec of directory dir
+	Profile func(name, dir string) string
+}
+
+// NewSpecLoader returns a loader of the spec directories of fsys, in which
+// profiles named in "extends" are sibling directories
+func NewSpecLoader(fsys fs.FS) *SpecLoader {
+	return &SpecLoader{
+		Dir: func(dir string) (fs.FS, error) {
+			return fs.Sub(fsys, dir)
+		},
+		Profile: func(name, dir string) string {
+			return path.Join(path.Dir(dir), name)
+		},
+	}
+}
+
+// Load returns the spec of category in dir merged on top of the specs it
+// extends, with the rules of the whole chain. An error for a missing spec
+// file wraps fs.ErrNotExist.
+func (l *SpecLoader) Load(dir, category string) (Spec, []Rule, error) {
+	return l.loadChain(dir, category, make(map[string]bool))
+}
+
+// loadChain loads a spec and, recursively, the specs it extends. visited
+// holds the spec files already on the chain to detect cycles.
+func (l *SpecLoader) loadChain(dir, category string, visited map[string]bool) (Spec, []Rule, error) {
+	jsonPath := fmt.Sprintf("%s/%s.json", dir, category)
+	if visited[jsonPath] {
+		return nil, nil, fmt.Errorf("spec JSON '%s' extends itself through a cycle", jsonPath)
+	}
+	visited[jsonPath] = true
+
+	fsys, err := l.Dir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read spec JSON '%s': %w", jsonPath, err)
+	}
+	raw, err := fs.ReadFile(fsys, category+".json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read spec JSON '%s': %w", jsonPath, err)
+	}
+	file, err := ParseSpecFile(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse spec JSON '%s': %v", jsonPath, err)
+	}
+
+	var parent Spec
+	var parentRules []Rule
+	if file.Extends != "" {
+		parentDir, parentCategory := l.resolveExtends(file.Extends, dir, category)
+		parent, parentRules, err = l.loadChain(parentDir, parentCategory, visited)
+		if err != nil {
+			return nil, nil, fmt.Errorf("spec JSON '%s' extends '%s': %v", jsonPath, file.Extends, err)
+		}
+	}
+	spec, err := file.Resolve(parent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse spec JSON '%s': %v", jsonPath, err)
+	}
+	return spec, MergeRules(parentRules, file.Rules), nil
+}
+
+// resolveExtends turns the "extends" value of a spec of dir into a spec
+// directory and category. It is either "<profile>/<Category>", a category of
+// the same directory ("Bass"), or a profile holding the same category
+// ("default").
+func (l *SpecLoader) resolveExtends(ref, dir, category string) (string, string) {
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		return l.Profile(ref[:i], dir), ref[i+1:]
+	}
+	if _, ok := CategoryCode(ref); ok {
+		return dir, ref
+	}
+	return l.Profile(ref, dir), category
+}
+
+// Categories returns the names of the spec files in dir, sorted. Files not
+// named after a category are included so callers can report them.
+func (l *SpecLoader) Categories(dir string) ([]string, error) {
+	fsys, err := l.Dir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec directory '%s': %v", dir, err)
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec directory '%s': %v", dir, err)
+	}
+	var categories []string
+	for _, e := range entries {
+		if e.IsDir() || strings.ToLower(path.Ext(e.Name())) != ".json" {
+			continue
+		}
+		categories = append(categories, strings.TrimSuffix(e.Name(), path.Ext(e.Name())))
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
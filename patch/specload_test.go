@@ -0,0 +1,93 @@
+package patch
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSpecLoaderLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"specs/Bass.json":      {Data: []byte(`{"FLT_Cutoff": {"min": 0, "max": 127, "sysex_offset": 36, "sysex_length": 1}, "rules": [{"name": "open", "rule": "FLT_Cutoff > 10"}]}`)},
+		"specs/Lead.json":      {Data: []byte(`{"extends": "Bass", "FLT_Cutoff": {"max": 90}}`)},
+		"specs/Pad.json":       {Data: []byte(`{"extends": "Pad"}`)},
+		"specs-dark/Bass.json": {Data: []byte(`{"extends": "specs", "FLT_Cutoff": {"min": 5}, "rules": [{"name": "open", "rule": "FLT_Cutoff > 20"}]}`)},
+		"specs-dark/Lead.json": {Data: []byte(`{"extends": "specs/Lead"}`)},
+		"specs-dark/Keys.json": {Data: []byte(`{"extends": "specs"}`)},
+		"specs-dark/Arp.json":  {Data: []byte(`{`)},
+	}
+	loader := NewSpecLoader(fsys)
+	tests := []struct {
+		dir, category string
+		min, max      int
+		rule          string
+		wantErr       bool
+	}{
+		{"specs", "Bass", 0, 127, "FLT_Cutoff > 10", false},
+		{"specs", "Lead", 0, 90, "FLT_Cutoff > 10", false},
+		{"specs-dark", "Bass", 5, 127, "FLT_Cutoff > 20", false},
+		{"specs-dark", "Lead", 0, 90, "FLT_Cutoff > 10", false},
+		{"specs", "Pad", 0, 0, "", true},        // extends itself
+		{"specs-dark", "Keys", 0, 0, "", true},  // parent is missing
+		{"specs-dark", "Arp", 0, 0, "", true},   // syntax error
+		{"specs-dark", "Organ", 0, 0, "", true}, // no spec file
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir+"/"+tt.category, func(t *testing.T) {
+			spec, rules, err := loader.Load(tt.dir, tt.category)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := spec["FLT_Cutoff"]
+			if info.Min != tt.min || info.Max != tt.max || info.SysexOffset != 36 {
+				t.Errorf("got FLT_Cutoff %+v, want range %d..%d at offset 36", info, tt.min, tt.max)
+			}
+			if len(rules) != 1 || rules[0].Rule != tt.rule {
+				t.Errorf("got rules %+v, want '%s'", rules, tt.rule)
+			}
+		})
+	}
+
+	// Only a missing spec file reads as not existing
+	if _, _, err := loader.Load("specs-dark", "Organ"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing spec: got %v, want fs.ErrNotExist", err)
+	}
+	for _, category := range []string{"Keys", "Arp"} {
+		if _, _, err := loader.Load("specs-dark", category); errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: got %v, not fs.ErrNotExist", category, err)
+		}
+	}
+}
+
+func TestSpecLoaderCategories(t *testing.T) {
+	fsys := fstest.MapFS{
+		"specs/Lead.json":        {Data: []byte(`{}`)},
+		"specs/Bass.JSON":        {Data: []byte(`{}`)},
+		"specs/updated_Pad.json": {Data: []byte(`{}`)},
+		"specs/Bass.syx":         {Data: []byte{}},
+		"specs/old/Pad.json":     {Data: []byte(`{}`)},
+	}
+	got, err := NewSpecLoader(fsys).Categories("specs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Bass", "Lead", "updated_Pad"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if _, err := NewSpecLoader(fsys).Categories("missing"); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
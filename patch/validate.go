@@ -0,0 +1,165 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Kinds of problems reported by validation
+const (
+	KindEmpty      = "empty"
+	KindTruncated  = "truncated"
+	KindBadHeader  = "bad-header"
+	KindMissingEnd = "missing-f7"
+	KindHighBit    = "high-bit"
+	KindOutOfRange = "out-of-range"
+)
+
+// ValidationError describes a single problem found in SysEx data
+type ValidationError struct {
+	Patch   int    // 0-based patch index
+	Offset  int    // byte offset in the file, -1 when not tied to a byte
+	Kind    string // one of the Kind* constants
+	Param   string // parameter name for out-of-range values
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Offset >= 0 {
+		return fmt.Sprintf("patch %d: %s at byte %d: %s", e.Patch+1, e.Kind, e.Offset, e.Message)
+	}
+	return fmt.Sprintf("patch %d: %s: %s", e.Patch+1, e.Kind, e.Message)
+}
+
+// Structural reports whether the problem makes the data unusable as SysEx.
+// Out-of-range values are still transmittable and only flagged.
+func (e ValidationError) Structural() bool {
+	return e.Kind != KindOutOfRange
+}
+
+// ValidationErrors is returned by loaders when data fails validation
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		lines[i] = e.Error()
+	}
+	return fmt.Sprintf("invalid sysex data, %d problem(s):\n  %s", len(errs), strings.Join(lines, "\n  "))
+}
+
+// Range holds the inclusive bounds allowed for a parameter
+type Range struct {
+	Min int
+	Max int
+}
+
+// Schema maps parameter names to the ranges allowed by the patch JSON schema
+type Schema map[string]Range
+
+// ParseSchema extracts parameter ranges from the patch JSON schema
+func ParseSchema(raw []byte) (Schema, error) {
+	var doc struct {
+		Properties map[string]struct {
+			Minimum int `json:"minimum"`
+			Maximum int `json:"maximum"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	schema := make(Schema, len(doc.Properties))
+	for name, prop := range doc.Properties {
+		schema[name] = Range{Min: prop.Minimum, Max: prop.Maximum}
+	}
+	return schema, nil
+}
+
+// ValidateStructure checks that data is a sequence of well-formed patches:
+// correct header, terminating F7, 7-bit data bytes and no truncated tail
+func ValidateStructure(data []byte) []ValidationError {
+	var errs []ValidationError
+	if len(data) == 0 {
+		return append(errs, ValidationError{Patch: 0, Offset: -1, Kind: KindEmpty, Message: "file contains no data"})
+	}
+
+	n := len(data) / Size
+	for i := 0; i < n; i++ {
+		errs = append(errs, validatePatchStructure(i, data[i*Size:(i+1)*Size])...)
+	}
+
+	if rem := len(data) % Size; rem != 0 {
+		errs = append(errs, ValidationError{
+			Patch:   n,
+			Offset:  n * Size,
+			Kind:    KindTruncated,
+			Message: fmt.Sprintf("%d trailing bytes do not form a complete %d-byte patch", rem, Size),
+		})
+	}
+	return errs
+}
+
+func validatePatchStructure(index int, p []byte) []ValidationError {
+	var errs []ValidationError
+	base := index * Size
+
+	for i, b := range Header {
+		if p[i] != b {
+			errs = append(errs, ValidationError{
+				Patch:   index,
+				Offset:  base + i,
+				Kind:    KindBadHeader,
+				Message: fmt.Sprintf("expected header % X, got % X", Header, p[:len(Header)]),
+			})
+			break
+		}
+	}
+
+	if p[Size-1] != EndOfExclusive {
+		errs = append(errs, ValidationError{
+			Patch:   index,
+			Offset:  base + Size - 1,
+			Kind:    KindMissingEnd,
+			Message: fmt.Sprintf("expected F7, got %02X", p[Size-1]),
+		})
+	}
+
+	for i := 1; i < Size-1; i++ {
+		if p[i] > 0x7F {
+			errs = append(errs, ValidationError{
+				Patch:   index,
+				Offset:  base + i,
+				Kind:    KindHighBit,
+				Message: fmt.Sprintf("data byte %02X has the high bit set", p[i]),
+			})
+		}
+	}
+	return errs
+}
+
+// ValidateRanges checks the parameters of a patch against the schema ranges,
+// using the offsets in spec. index is the position of the patch in its file.
+func ValidateRanges(index int, p *Patch, spec Spec, schema Schema) []ValidationError {
+	var errs []ValidationError
+	for _, name := range spec.Names() {
+		r, ok := schema[name]
+		if !ok {
+			continue
+		}
+		val, err := p.Param(spec, name)
+		if err != nil {
+			continue
+		}
+		if val < r.Min || val > r.Max {
+			errs = append(errs, ValidationError{
+				Patch:   index,
+				Offset:  index*Size + spec[name].SysexOffset,
+				Kind:    KindOutOfRange,
+				Param:   name,
+				Message: fmt.Sprintf("%s = %d outside schema range %d..%d", name, val, r.Min, r.Max),
+			})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,50 @@
+package patch
+
+import "testing"
+
+// rawPatch returns the bytes of a well-formed patch with all parameters at 0
+func rawPatch() []byte {
+	data := make([]byte, Size)
+	copy(data, Header)
+	data[Size-1] = EndOfExclusive
+	return data
+}
+
+func TestValidateStructure(t *testing.T) {
+	bundle := append(rawPatch(), rawPatch()...)
+	tests := []struct {
+		name  string
+		data  func() []byte
+		kinds []string
+	}{
+		{"valid patch", rawPatch, nil},
+		{"valid bundle", func() []byte { return bundle }, nil},
+		{"empty", func() []byte { return nil }, []string{KindEmpty}},
+		{"truncated", func() []byte { return append(rawPatch(), 0xF0, 0x00) }, []string{KindTruncated}},
+		{"short", func() []byte { return rawPatch()[:100] }, []string{KindTruncated}},
+		{"bad header", func() []byte { d := rawPatch(); d[4] = 0x4E; return d }, []string{KindBadHeader}},
+		{"missing F7", func() []byte { d := rawPatch(); d[Size-1] = 0; return d }, []string{KindMissingEnd}},
+		{"high bit", func() []byte { d := rawPatch(); d[30] = 0x80; return d }, []string{KindHighBit}},
+		{"second patch", func() []byte {
+			d := append(rawPatch(), rawPatch()...)
+			d[Size+40] = 0x90
+			return d
+		}, []string{KindHighBit}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateStructure(tt.data())
+			if len(errs) != len(tt.kinds) {
+				t.Fatalf("got %d problems %v, want %v", len(errs), errs, tt.kinds)
+			}
+			for i, e := range errs {
+				if e.Kind != tt.kinds[i] {
+					t.Errorf("problem %d: kind %s, want %s", i, e.Kind, tt.kinds[i])
+				}
+				if !e.Structural() {
+					t.Errorf("problem %d: %s is not structural", i, e.Kind)
+				}
+			}
+		})
+	}
+}